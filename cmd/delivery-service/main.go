@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/assignment"
 	"github.com/purnama/Event-Driven-Logistic/internal/delivery/dellivery"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/eta"
 	deliveryEvent "github.com/purnama/Event-Driven-Logistic/internal/delivery/event"
 	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
 	"github.com/purnama/Event-Driven-Logistic/internal/delivery/service"
+	"github.com/purnama/Event-Driven-Logistic/pkg/admin"
+	"github.com/purnama/Event-Driven-Logistic/pkg/blobstore"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
 	"github.com/purnama/Event-Driven-Logistic/pkg/config"
 	"github.com/purnama/Event-Driven-Logistic/pkg/database"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+	"github.com/purnama/Event-Driven-Logistic/pkg/lifecycle"
 	"github.com/purnama/Event-Driven-Logistic/pkg/middleware"
 )
 
@@ -20,42 +28,84 @@ func main() {
 	cfg := config.LoadConfig()
 	cfg.PrintConfig()
 
+	lc := lifecycle.New(cfg.Server.ShutdownTimeout)
+
 	db := database.InitPostgres(cfg.Database.URL)
 	log.Println("📦 Running database migrations...")
-	if err := db.AutoMigrate(&repository.Shipment{}); err != nil {
+	if err := db.AutoMigrate(&repository.Courier{}, &repository.Shipment{}, &repository.ShipmentLocation{}, &repository.OrderDestination{}, &repository.DeliveryProof{}, &broker.OutboxMessage{}, &broker.ProcessedEvent{}); err != nil {
 		log.Fatalf("❌ Failed to run migrations: %v", err)
 	}
 	log.Println("✅ Database migrations completed successfully")
 
+	strategy, err := assignment.New(cfg.Delivery.AssignmentStrategy)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	depot := geo.Point{Lat: cfg.Delivery.DepotLat, Long: cfg.Delivery.DepotLong}
+	if err := depot.Validate(); err != nil {
+		log.Fatalf("❌ Lokasi depot tidak valid: %v", err)
+	}
+	estimator, err := eta.New(cfg.Delivery.AverageSpeedKmh)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	blobs, err := blobstore.New(cfg.Blobstore.Driver, cfg.Blobstore.Dir)
+	if err != nil {
+		log.Fatalf("❌ Failed to open blobstore: %v", err)
+	}
+
 	shipmentRepo := repository.NewShipmentRepository(db)
-	shipmentSvc := service.NewShipmentService(shipmentRepo)
+	shipmentSvc := service.NewShipmentService(shipmentRepo, strategy, blobs, service.Config{
+		Depot:                 depot,
+		LocationEventInterval: cfg.Delivery.LocationEventInterval,
+		ETA:                   estimator,
+		ETAChangeThreshold:    cfg.Delivery.ETAChangeThreshold,
+		PickupGeofenceKm:      cfg.Delivery.PickupGeofenceRadiusM / 1000,
+		DestinationGeofenceKm: cfg.Delivery.DestinationGeofenceRadiusM / 1000,
+	})
+	courierSvc := service.NewCourierService(repository.NewCourierRepository(db), shipmentSvc)
 
-	mqConn := broker.ConnectRabbitMQ()
-	defer mqConn.Close()
-	consChan := broker.CreateChannel(mqConn)
-	defer consChan.Close()
-	consumer, err := broker.NewConsumer(consChan)
+	mqConn := broker.NewConnection(cfg.RabbitMQ.URL)
+	publisher, err := broker.NewPublisher(mqConn)
+	if err != nil {
+		log.Fatalf("❌ Failed to create publisher: %v", err)
+	}
+	relay := broker.NewOutboxRelay(db, publisher)
+	lc.Go("Outbox relay", relay.Run)
+
+	consumer, err := broker.NewConsumer(mqConn)
 	if err != nil {
 		log.Fatalf("❌ Failed to create consumer: %v", err)
 	}
+	consumer.Use(broker.NewProcessedEventStore(db).Middleware())
 	delConsumer := deliveryEvent.NewDeliveryConsumer(consumer, shipmentSvc)
 	if err := delConsumer.StartListening(); err != nil {
 		log.Fatalf("❌ Failed to start delivery consumer: %v", err)
 	}
 
 	handler := dellivery.NewShipmentHandler(shipmentSvc)
+	courierHandler := dellivery.NewCourierHandler(courierSvc)
 
 	router := gin.Default()
 	router.Use(middleware.CORSMiddleware()) // CORS untuk dashboard
+	router.Use(middleware.CorrelationID())  // X-Correlation-ID untuk tracing
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy", "service": "delivery-service", "version": "1.0.0"})
 	})
 
-	dellivery.RegisterRoutes(router, handler)
+	dellivery.RegisterRoutes(router, handler, courierHandler)
+	admin.RegisterRoutes(router, admin.NewDeadLetterHandler(broker.NewDeadLetterManager(consumer, publisher)), cfg.Admin.Token)
+
+	lc.OnShutdown("consumer", consumer.Shutdown)
+	lc.OnShutdown("publisher", publisher.Close)
+	lc.OnShutdown("rabbitmq", func(ctx context.Context) error { return mqConn.Close() })
+	lc.OnShutdown("database", func(ctx context.Context) error { return database.Close(db) })
 
 	log.Printf("✅ Delivery Service is running on port %s", cfg.Server.Port)
-	if err := router.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("❌ Failed to start server: %v", err)
+	server := &http.Server{Addr: ":" + cfg.Server.Port, Handler: router}
+	if err := lc.Run(server); err != nil {
+		log.Fatalf("❌ Shutdown tidak bersih: %v", err)
 	}
+	log.Println("👋 Delivery Service stopped")
 }
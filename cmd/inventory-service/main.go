@@ -1,16 +1,20 @@
 package main
 
 import (
-	"log" // Logging
+	"context"
+	"log"
+	"net/http" // Logging
 
 	"github.com/gin-gonic/gin"
 	"github.com/purnama/Event-Driven-Logistic/internal/inventory/dellivery"
 	inventoryEvent "github.com/purnama/Event-Driven-Logistic/internal/inventory/event"
 	"github.com/purnama/Event-Driven-Logistic/internal/inventory/repository"
 	"github.com/purnama/Event-Driven-Logistic/internal/inventory/service"
+	"github.com/purnama/Event-Driven-Logistic/pkg/admin"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
 	"github.com/purnama/Event-Driven-Logistic/pkg/config"
 	"github.com/purnama/Event-Driven-Logistic/pkg/database"
+	"github.com/purnama/Event-Driven-Logistic/pkg/lifecycle"
 	"github.com/purnama/Event-Driven-Logistic/pkg/middleware"
 )
 
@@ -20,12 +24,23 @@ func main() {
 	cfg := config.LoadConfig()
 	cfg.PrintConfig()
 
+	lc := lifecycle.New(cfg.Server.ShutdownTimeout)
+
 	db := database.InitPostgres(cfg.Database.URL)
 
 	log.Println("📦 Running database migrations...")
+	// Reservasi kini satu per produk dalam order; index unik lama di order_id dibuang
+	if db.Migrator().HasIndex(&repository.StockReservation{}, "idx_stock_reservations_order_id") {
+		if err := db.Migrator().DropIndex(&repository.StockReservation{}, "idx_stock_reservations_order_id"); err != nil {
+			log.Fatalf("❌ Failed to drop old reservation index: %v", err)
+		}
+	}
 	if err := db.AutoMigrate(
 		&repository.Product{},
 		&repository.StockReservation{},
+		&repository.CancelledOrder{},
+		&broker.OutboxMessage{},
+		&broker.ProcessedEvent{},
 	); err != nil {
 		log.Fatalf("❌ Failed to run migrations: %v", err)
 	}
@@ -34,21 +49,26 @@ func main() {
 	productRepo := repository.NewProductRepository(db)
 	reservationRepo := repository.NewStockReservationRepository(db)
 	inventorySvc := service.NewInventoryService(productRepo, reservationRepo)
-	mqConn := broker.ConnectRabbitMQ()
-	defer mqConn.Close()
-	pubChan := broker.CreateChannel(mqConn)
-	defer pubChan.Close()
-	publisher, err := broker.NewPublisher(pubChan)
+	mqConn := broker.NewConnection(cfg.RabbitMQ.URL)
+	publisher, err := broker.NewPublisher(mqConn)
 	if err != nil {
 		log.Fatalf("❌ Failed to create publisher: %v", err)
 	}
-	consChan := broker.CreateChannel(mqConn)
-	defer consChan.Close()
-	consumer, err := broker.NewConsumer(consChan)
+	relay := broker.NewOutboxRelay(db, publisher)
+	lc.Go("Outbox relay", relay.Run)
+	consumer, err := broker.NewConsumer(mqConn)
 	if err != nil {
 		log.Fatalf("❌ Failed to create consumer: %v", err)
 	}
-	invConsumer := inventoryEvent.NewInventoryConsumer(consumer, publisher, inventorySvc)
+	consumer.Use(broker.NewProcessedEventStore(db).Middleware())
+	reserveOpts := inventoryEvent.DefaultReserveOptions()
+	if cfg.RabbitMQ.Workers > 0 {
+		reserveOpts.Workers = cfg.RabbitMQ.Workers
+	}
+	if cfg.RabbitMQ.Prefetch > 0 {
+		reserveOpts.Prefetch = cfg.RabbitMQ.Prefetch
+	}
+	invConsumer := inventoryEvent.NewInventoryConsumer(consumer, inventorySvc, reserveOpts)
 	if err := invConsumer.StartListening(); err != nil {
 		log.Fatalf("❌ Failed to start inventory consumer: %v", err)
 	}
@@ -56,15 +76,24 @@ func main() {
 
 	router := gin.Default()
 	router.Use(middleware.CORSMiddleware()) // CORS untuk dashboard
+	router.Use(middleware.CorrelationID())  // X-Correlation-ID untuk tracing
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy", "service": "inventory-service", "version": "1.0.0"})
 	})
 
 	dellivery.RegisterRoutes(router, handler)
+	admin.RegisterRoutes(router, admin.NewDeadLetterHandler(broker.NewDeadLetterManager(consumer, publisher)), cfg.Admin.Token)
+
+	lc.OnShutdown("consumer", consumer.Shutdown)
+	lc.OnShutdown("publisher", publisher.Close)
+	lc.OnShutdown("rabbitmq", func(ctx context.Context) error { return mqConn.Close() })
+	lc.OnShutdown("database", func(ctx context.Context) error { return database.Close(db) })
 
 	log.Printf("✅ Inventory Service is running on port %s", cfg.Server.Port)
-	if err := router.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("❌ Failed to start server: %v", err)
+	server := &http.Server{Addr: ":" + cfg.Server.Port, Handler: router}
+	if err := lc.Run(server); err != nil {
+		log.Fatalf("❌ Shutdown tidak bersih: %v", err)
 	}
+	log.Println("👋 Inventory Service stopped")
 }
@@ -16,6 +16,7 @@ package main
 // ============================================================================
 
 import (
+	"context"       // Shutdown hooks
 	"html/template" // Go HTML templates
 	"log"           // Logging
 	"net/http"      // HTTP handlers
@@ -26,9 +27,12 @@ import (
 	"github.com/purnama/Event-Driven-Logistic/internal/notification/repository"        // DB models + repo
 	"github.com/purnama/Event-Driven-Logistic/internal/notification/service"           // Business logic
 	notifWs "github.com/purnama/Event-Driven-Logistic/internal/notification/websocket" // WebSocket hub+handler
+	"github.com/purnama/Event-Driven-Logistic/pkg/admin"                               // Dead-letter admin API
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"                              // RabbitMQ helpers
 	"github.com/purnama/Event-Driven-Logistic/pkg/config"                              // Config loader
 	"github.com/purnama/Event-Driven-Logistic/pkg/database"                            // Database helper
+	"github.com/purnama/Event-Driven-Logistic/pkg/lifecycle"                           // Graceful shutdown
+	"github.com/purnama/Event-Driven-Logistic/pkg/middleware"                          // Correlation ID
 )
 
 func main() {
@@ -38,12 +42,14 @@ func main() {
 	cfg := config.LoadConfig() // Baca DB_URL, MQ_URL, PORT
 	cfg.PrintConfig()          // Tampilkan (password di-mask)
 
+	lc := lifecycle.New(cfg.Server.ShutdownTimeout) // Graceful shutdown (SIGINT/SIGTERM)
+
 	// ── Step 2: Koneksi ke PostgreSQL ──
 	db := database.InitPostgres(cfg.Database.URL) // Koneksi ke db_notification
 
 	// ── Step 3: AutoMigrate ──
 	log.Println("📦 Running database migrations...")
-	if err := db.AutoMigrate(&repository.NotificationLog{}); err != nil { // Migrate model
+	if err := db.AutoMigrate(&repository.NotificationLog{}, &broker.ProcessedEvent{}); err != nil { // Migrate model
 		log.Fatalf("❌ Failed to run migrations: %v", err)
 	}
 	log.Println("✅ Database migrations completed successfully")
@@ -59,14 +65,16 @@ func main() {
 	notifSvc := service.NewNotificationService(repo, hub) // Layer 2: Business logic
 
 	// ── Step 7: Setup RabbitMQ Consumer ──
-	mqConn := broker.ConnectRabbitMQ()            // Koneksi ke RabbitMQ
-	defer mqConn.Close()                          // Tutup saat shutdown
-	consChan := broker.CreateChannel(mqConn)      // Buat channel untuk consume
-	defer consChan.Close()                        // Tutup saat shutdown
-	consumer, err := broker.NewConsumer(consChan) // Buat consumer
+	mqConn := broker.NewConnection(cfg.RabbitMQ.URL) // Koneksi ke RabbitMQ
+	publisher, err := broker.NewPublisher(mqConn)    // Publisher untuk replay
+	if err != nil {
+		log.Fatalf("❌ Failed to create publisher: %v", err)
+	}
+	consumer, err := broker.NewConsumer(mqConn) // Buat consumer
 	if err != nil {
 		log.Fatalf("❌ Failed to create consumer: %v", err)
 	}
+	consumer.Use(broker.NewProcessedEventStore(db).Middleware())       // Lewati event duplikat
 	notifConsumer := event.NewNotificationConsumer(consumer, notifSvc) // Wire consumer
 	if err := notifConsumer.StartListening(); err != nil {             // Mulai listen ALL events
 		log.Fatalf("❌ Failed to start notification consumer: %v", err)
@@ -74,6 +82,7 @@ func main() {
 
 	// ── Step 8: Setup Gin router ──
 	router := gin.Default()
+	router.Use(middleware.CorrelationID()) // X-Correlation-ID untuk tracing
 
 	// Load HTML templates from project root /templates/
 	templatePath := filepath.Join("..", "..", "templates", "*.html") // Relative dari cmd/notification-service/
@@ -119,11 +128,33 @@ func main() {
 		c.JSON(200, gin.H{"logs": logs}) // 200 OK
 	})
 
-	// ── Step 9: Start HTTP server ──
+	// ── API: Semua event dalam satu alur (correlation ID) ──
+	router.GET("/api/traces/:correlation_id", func(c *gin.Context) {
+		correlationID := c.Param("correlation_id")                  // Ambil correlation_id dari URL
+		logs, err := notifSvc.GetLogsByCorrelationID(correlationID) // Query DB
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()}) // 500 Error
+			return
+		}
+		c.JSON(200, gin.H{"logs": logs}) // 200 OK
+	})
+
+	// ── Admin: inspeksi & replay dead-letter queue ──
+	admin.RegisterRoutes(router, admin.NewDeadLetterHandler(broker.NewDeadLetterManager(consumer, publisher)), cfg.Admin.Token)
+
+	// ── Step 9: Urutan shutdown: HTTP → consumer → publisher → RabbitMQ → DB ──
+	lc.OnShutdown("consumer", consumer.Shutdown) // Tunggu handler selesai ack/nack
+	lc.OnShutdown("publisher", publisher.Close)  // Tunggu confirm yang tertunda
+	lc.OnShutdown("rabbitmq", func(ctx context.Context) error { return mqConn.Close() })
+	lc.OnShutdown("database", func(ctx context.Context) error { return database.Close(db) })
+
+	// ── Step 10: Start HTTP server ──
 	log.Printf("✅ Notification Service is running on port %s", cfg.Server.Port)
 	log.Printf("🌐 Dashboard: http://localhost:%s", cfg.Server.Port)
 	log.Printf("🔌 WebSocket: ws://localhost:%s/ws", cfg.Server.Port)
-	if err := router.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("❌ Failed to start server: %v", err)
+	server := &http.Server{Addr: ":" + cfg.Server.Port, Handler: router}
+	if err := lc.Run(server); err != nil {
+		log.Fatalf("❌ Shutdown tidak bersih: %v", err)
 	}
+	log.Println("👋 Notification Service stopped")
 }
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/internal/order/catalog"
 	"github.com/purnama/Event-Driven-Logistic/internal/order/delivery"
 	"github.com/purnama/Event-Driven-Logistic/internal/order/event"
 	"github.com/purnama/Event-Driven-Logistic/internal/order/repository"
 	"github.com/purnama/Event-Driven-Logistic/internal/order/service"
+	"github.com/purnama/Event-Driven-Logistic/pkg/admin"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
 	"github.com/purnama/Event-Driven-Logistic/pkg/config"
 	"github.com/purnama/Event-Driven-Logistic/pkg/database"
+	"github.com/purnama/Event-Driven-Logistic/pkg/lifecycle"
 	"github.com/purnama/Event-Driven-Logistic/pkg/middleware"
 )
 
@@ -20,51 +25,59 @@ func main() {
 	cfg := config.LoadConfig()
 	cfg.PrintConfig()
 
+	lc := lifecycle.New(cfg.Server.ShutdownTimeout)
+
 	db := database.InitPostgres(cfg.Database.URL)
 
 	log.Println("📦 Running database migrations...")
-	if err := db.AutoMigrate(&repository.Order{}); err != nil {
+	if err := db.AutoMigrate(&repository.Order{}, &repository.OrderItem{}, &repository.OrderStatusHistory{}, &broker.OutboxMessage{}, &broker.ProcessedEvent{}); err != nil {
 		log.Fatalf("❌ Failed to run migrations: %v", err)
 	}
 	log.Println("✅ Database migrations completed successfully")
 
 	repo := repository.NewOrderRepository(db)
-	svc := service.NewOrderService(repo)
+	svc := service.NewOrderService(repo, catalog.NewInventoryClient(cfg.Services.InventoryURL))
 
-	mqConn := broker.ConnectRabbitMQ()
-	defer mqConn.Close()
-	pubChan := broker.CreateChannel(mqConn)
-	defer pubChan.Close()
-	publisher, err := broker.NewPublisher(pubChan)
+	mqConn := broker.NewConnection(cfg.RabbitMQ.URL)
+	publisher, err := broker.NewPublisher(mqConn)
 	if err != nil {
 		log.Fatalf("❌ Failed to create publisher: %v", err)
 	}
-	orderPublisher := event.NewOrderPublisher(publisher)
+	relay := broker.NewOutboxRelay(db, publisher)
+	lc.Go("Outbox relay", relay.Run)
 
-	consChan := broker.CreateChannel(mqConn)
-	defer consChan.Close()
-	consumer, err := broker.NewConsumer(consChan)
+	consumer, err := broker.NewConsumer(mqConn)
 	if err != nil {
 		log.Fatalf("❌ Failed to create consumer: %v", err)
 	}
-	orderConsumer := event.NewOrderConsumer(consumer, repo)
+	consumer.Use(broker.NewProcessedEventStore(db).Middleware())
+	orderConsumer := event.NewOrderConsumer(consumer, svc)
 	if err := orderConsumer.StartListening(); err != nil {
 		log.Fatalf("❌ Failed to start order consumer: %v", err)
 	}
 
-	handler := delivery.NewOrderHandler(svc, orderPublisher)
+	handler := delivery.NewOrderHandler(svc)
 
 	router := gin.Default()
 	router.Use(middleware.CORSMiddleware()) // CORS untuk dashboard
+	router.Use(middleware.CorrelationID())  // X-Correlation-ID untuk tracing
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy", "service": "order-service", "version": "1.0.0"})
 	})
 
 	delivery.RegisterRoutes(router, handler)
+	admin.RegisterRoutes(router, admin.NewDeadLetterHandler(broker.NewDeadLetterManager(consumer, publisher)), cfg.Admin.Token)
+
+	lc.OnShutdown("consumer", consumer.Shutdown)
+	lc.OnShutdown("publisher", publisher.Close)
+	lc.OnShutdown("rabbitmq", func(ctx context.Context) error { return mqConn.Close() })
+	lc.OnShutdown("database", func(ctx context.Context) error { return database.Close(db) })
 
 	log.Printf("✅ Order Service is running on port %s", cfg.Server.Port)
-	if err := router.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("❌ Failed to start server: %v", err)
+	server := &http.Server{Addr: ":" + cfg.Server.Port, Handler: router}
+	if err := lc.Run(server); err != nil {
+		log.Fatalf("❌ Shutdown tidak bersih: %v", err)
 	}
+	log.Println("👋 Order Service stopped")
 }
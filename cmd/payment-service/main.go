@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/purnama/Event-Driven-Logistic/internal/payment/dellivery"
 	"github.com/purnama/Event-Driven-Logistic/internal/payment/event"
 	"github.com/purnama/Event-Driven-Logistic/internal/payment/repository"
 	"github.com/purnama/Event-Driven-Logistic/internal/payment/service"
+	"github.com/purnama/Event-Driven-Logistic/pkg/admin"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
 	"github.com/purnama/Event-Driven-Logistic/pkg/config"
 	"github.com/purnama/Event-Driven-Logistic/pkg/database"
+	"github.com/purnama/Event-Driven-Logistic/pkg/lifecycle"
 	"github.com/purnama/Event-Driven-Logistic/pkg/middleware"
 )
 
@@ -20,9 +24,11 @@ func main() {
 	cfg := config.LoadConfig()
 	cfg.PrintConfig()
 
+	lc := lifecycle.New(cfg.Server.ShutdownTimeout)
+
 	db := database.InitPostgres(cfg.Database.URL)
 	log.Println("📦 Running database migrations...")
-	if err := db.AutoMigrate(&repository.Payment{}); err != nil {
+	if err := db.AutoMigrate(&repository.Payment{}, &repository.Refund{}, &repository.CancelledOrder{}, &broker.OutboxMessage{}, &broker.ProcessedEvent{}); err != nil {
 		log.Fatalf("❌ Failed to run migrations: %v", err)
 	}
 	log.Println("✅ Database migrations completed successfully")
@@ -30,38 +36,46 @@ func main() {
 	paymentRepo := repository.NewPaymentRepository(db)
 	paymentSvc := service.NewPaymentService(paymentRepo)
 
-	mqConn := broker.ConnectRabbitMQ()
-	defer mqConn.Close()
-	pubChan := broker.CreateChannel(mqConn)
-	defer pubChan.Close()
-	publisher, err := broker.NewPublisher(pubChan)
+	mqConn := broker.NewConnection(cfg.RabbitMQ.URL)
+	publisher, err := broker.NewPublisher(mqConn)
 	if err != nil {
 		log.Fatalf("❌ Failed to create publisher: %v", err)
 	}
-	paymentPublisher := event.NewPaymentPublisher(publisher)
-	consChan := broker.CreateChannel(mqConn)
-	defer consChan.Close()
-	consumer, err := broker.NewConsumer(consChan)
+	relay := broker.NewOutboxRelay(db, publisher)
+	lc.Go("Outbox relay", relay.Run)
+	lc.Go("Payment expiry worker", service.NewExpiryWorker(paymentSvc, cfg.Payment.ExpiryTTL, cfg.Payment.ExpiryInterval).Run)
+
+	consumer, err := broker.NewConsumer(mqConn)
 	if err != nil {
 		log.Fatalf("❌ Failed to create consumer: %v", err)
 	}
+	consumer.Use(broker.NewProcessedEventStore(db).Middleware())
 	paymentConsumer := event.NewPaymentConsumer(consumer, paymentSvc)
 	if err := paymentConsumer.StartListening(); err != nil {
 		log.Fatalf("❌ Failed to start payment consumer: %v", err)
 	}
 
-	handler := dellivery.NewPaymentHandler(paymentSvc, paymentPublisher)
+	handler := dellivery.NewPaymentHandler(paymentSvc)
 	router := gin.Default()
 	router.Use(middleware.CORSMiddleware()) // CORS untuk dashboard
+	router.Use(middleware.CorrelationID())  // X-Correlation-ID untuk tracing
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy", "service": "payment-service", "version": "1.0.0"})
 	})
 
 	dellivery.RegisterRoutes(router, handler)
+	admin.RegisterRoutes(router, admin.NewDeadLetterHandler(broker.NewDeadLetterManager(consumer, publisher)), cfg.Admin.Token)
+
+	lc.OnShutdown("consumer", consumer.Shutdown)
+	lc.OnShutdown("publisher", publisher.Close)
+	lc.OnShutdown("rabbitmq", func(ctx context.Context) error { return mqConn.Close() })
+	lc.OnShutdown("database", func(ctx context.Context) error { return database.Close(db) })
 
 	log.Printf("✅ Payment Service is running on port %s", cfg.Server.Port)
-	if err := router.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("❌ Failed to start server: %v", err)
+	server := &http.Server{Addr: ":" + cfg.Server.Port, Handler: router}
+	if err := lc.Run(server); err != nil {
+		log.Fatalf("❌ Shutdown tidak bersih: %v", err)
 	}
+	log.Println("👋 Payment Service stopped")
 }
@@ -0,0 +1,120 @@
+// Package assignment berisi strategi pemilihan kurir untuk shipment baru.
+package assignment
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+)
+
+// Nama strategi yang bisa dipilih lewat ASSIGNMENT_STRATEGY.
+const (
+	Nearest     = "nearest"
+	LeastLoaded = "least_loaded"
+	RoundRobin  = "round_robin"
+)
+
+// Strategy memilih satu kurir dari kandidat yang tersedia dan masih punya
+// kapasitas. pickup adalah lokasi penjemputan paket.
+type Strategy interface {
+	Name() string
+	Pick(candidates []repository.CourierLoad, pickup geo.Point) (repository.CourierLoad, bool)
+}
+
+// New membuat strategi berdasarkan namanya.
+func New(name string) (Strategy, error) {
+	switch name {
+	case Nearest:
+		return nearest{}, nil
+	case LeastLoaded:
+		return leastLoaded{}, nil
+	case RoundRobin:
+		return &roundRobin{}, nil
+	}
+	return nil, fmt.Errorf("strategi assignment tidak dikenal: %q (pilih %s, %s atau %s)",
+		name, Nearest, LeastLoaded, RoundRobin)
+}
+
+// nearest memilih kurir yang lokasi asalnya paling dekat dengan titik
+// penjemputan; jarak sama dimenangkan kurir dengan beban paling ringan.
+type nearest struct{}
+
+func (nearest) Name() string { return Nearest }
+
+func (nearest) Pick(candidates []repository.CourierLoad, pickup geo.Point) (repository.CourierLoad, bool) {
+	return best(candidates, func(a, b repository.CourierLoad) bool {
+		da, db := geo.DistanceKm(home(a), pickup), geo.DistanceKm(home(b), pickup)
+		if da != db {
+			return da < db
+		}
+		return lighter(a, b)
+	})
+}
+
+// leastLoaded memilih kurir dengan rasio shipment aktif terhadap kapasitas
+// paling kecil.
+type leastLoaded struct{}
+
+func (leastLoaded) Name() string { return LeastLoaded }
+
+func (leastLoaded) Pick(candidates []repository.CourierLoad, _ geo.Point) (repository.CourierLoad, bool) {
+	return best(candidates, lighter)
+}
+
+// roundRobin memilih kurir bergiliran berdasarkan urutan ID. Posisi giliran
+// disimpan di memori, jadi setiap replika punya gilirannya sendiri.
+type roundRobin struct {
+	mu   sync.Mutex
+	last uint
+}
+
+func (*roundRobin) Name() string { return RoundRobin }
+
+func (r *roundRobin) Pick(candidates []repository.CourierLoad, _ geo.Point) (repository.CourierLoad, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Kurir berikutnya adalah ID terkecil setelah giliran terakhir;
+	// jika tidak ada, putaran kembali ke ID terkecil.
+	next, ok := best(candidates, func(a, b repository.CourierLoad) bool {
+		afterA, afterB := a.ID > r.last, b.ID > r.last
+		if afterA != afterB {
+			return afterA
+		}
+		return a.ID < b.ID
+	})
+	if ok {
+		r.last = next.ID
+	}
+	return next, ok
+}
+
+// best mengembalikan kandidat terbaik menurut less.
+func best(candidates []repository.CourierLoad, less func(a, b repository.CourierLoad) bool) (repository.CourierLoad, bool) {
+	if len(candidates) == 0 {
+		return repository.CourierLoad{}, false
+	}
+	chosen := candidates[0]
+	for _, c := range candidates[1:] {
+		if less(c, chosen) {
+			chosen = c
+		}
+	}
+	return chosen, true
+}
+
+// lighter membandingkan rasio beban a dan b tanpa pembagian float;
+// rasio sama dimenangkan ID terkecil agar hasilnya deterministik.
+func lighter(a, b repository.CourierLoad) bool {
+	la, lb := a.ActiveShipments*b.Capacity, b.ActiveShipments*a.Capacity
+	if la != lb {
+		return la < lb
+	}
+	return a.ID < b.ID
+}
+
+func home(c repository.CourierLoad) geo.Point {
+	return geo.Point{Lat: c.HomeLat, Long: c.HomeLong}
+}
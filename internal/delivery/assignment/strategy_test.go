@@ -0,0 +1,88 @@
+package assignment
+
+import (
+	"testing"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+)
+
+var depot = geo.Point{Lat: -6.2, Long: 106.8}
+
+func courier(id uint, capacity, active int, lat, long float64) repository.CourierLoad {
+	return repository.CourierLoad{
+		Courier: repository.Courier{
+			ID: id, Capacity: capacity, Available: true,
+			VehicleType: repository.VehicleMotorcycle, HomeLat: lat, HomeLong: long,
+		},
+		ActiveShipments: active,
+	}
+}
+
+func pickID(t *testing.T, s Strategy, candidates []repository.CourierLoad) uint {
+	t.Helper()
+	c, ok := s.Pick(candidates, depot)
+	if !ok {
+		t.Fatalf("%s: tidak ada kurir terpilih", s.Name())
+	}
+	return c.ID
+}
+
+func TestNearest(t *testing.T) {
+	s, _ := New(Nearest)
+	candidates := []repository.CourierLoad{
+		courier(1, 1, 0, -6.9, 107.6), // Bandung
+		courier(2, 1, 0, -6.21, 106.81),
+		courier(3, 1, 0, -6.3, 106.9),
+	}
+	if got := pickID(t, s, candidates); got != 2 {
+		t.Fatalf("nearest = %d, want 2", got)
+	}
+
+	// Jarak sama: beban lebih ringan menang
+	tie := []repository.CourierLoad{courier(1, 2, 1, -6.21, 106.81), courier(2, 2, 0, -6.21, 106.81)}
+	if got := pickID(t, s, tie); got != 2 {
+		t.Fatalf("nearest tie = %d, want 2", got)
+	}
+}
+
+func TestLeastLoaded(t *testing.T) {
+	s, _ := New(LeastLoaded)
+	candidates := []repository.CourierLoad{
+		courier(1, 2, 1, 0, 0), // 50%
+		courier(2, 4, 1, 0, 0), // 25%
+		courier(3, 1, 0, 0, 0), // 0%, ID terkecil di antara kurir kosong
+		courier(4, 3, 0, 0, 0),
+	}
+	if got := pickID(t, s, candidates); got != 3 {
+		t.Fatalf("least loaded = %d, want 3", got)
+	}
+	if got := pickID(t, s, candidates[:2]); got != 2 {
+		t.Fatalf("least loaded = %d, want 2", got)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	s, _ := New(RoundRobin)
+	candidates := []repository.CourierLoad{courier(5, 1, 0, 0, 0), courier(2, 1, 0, 0, 0), courier(9, 1, 0, 0, 0)}
+
+	var got []uint
+	for i := 0; i < 4; i++ {
+		got = append(got, pickID(t, s, candidates))
+	}
+	want := []uint{2, 5, 9, 2}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("round robin = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestNewUnknownStrategy(t *testing.T) {
+	if _, err := New("random"); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+	if _, ok := (nearest{}).Pick(nil, depot); ok {
+		t.Fatal("expected no pick without candidates")
+	}
+}
@@ -0,0 +1,121 @@
+package dellivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/service"
+	"github.com/purnama/Event-Driven-Logistic/pkg/response"
+)
+
+type CourierHandler struct {
+	svc service.CourierService
+}
+
+func NewCourierHandler(svc service.CourierService) *CourierHandler {
+	return &CourierHandler{svc: svc}
+}
+
+func (h *CourierHandler) ListCouriers(c *gin.Context) {
+	couriers, err := h.svc.ListCouriers()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Gagal mengambil daftar kurir")
+		return
+	}
+
+	response.Success(c, "Daftar kurir berhasil diambil", couriers)
+}
+
+func (h *CourierHandler) GetCourierByID(c *gin.Context) {
+	id, ok := courierID(c)
+	if !ok {
+		return
+	}
+
+	courier, err := h.svc.GetCourierByID(id)
+	if err != nil {
+		writeCourierError(c, "Gagal mengambil kurir", err)
+		return
+	}
+
+	response.Success(c, "Kurir ditemukan", courier)
+}
+
+func (h *CourierHandler) CreateCourier(c *gin.Context) {
+	var req service.CreateCourierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Request tidak valid: "+err.Error())
+		return
+	}
+
+	courier, err := h.svc.CreateCourier(c.Request.Context(), req)
+	if err != nil {
+		writeCourierError(c, "Gagal membuat kurir", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.Response{
+		Status:  "success",
+		Message: "Kurir berhasil dibuat",
+		Data:    courier,
+	})
+}
+
+func (h *CourierHandler) UpdateCourier(c *gin.Context) {
+	id, ok := courierID(c)
+	if !ok {
+		return
+	}
+
+	var req service.UpdateCourierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Request tidak valid: "+err.Error())
+		return
+	}
+
+	courier, err := h.svc.UpdateCourier(c.Request.Context(), id, req)
+	if err != nil {
+		writeCourierError(c, "Gagal update kurir", err)
+		return
+	}
+
+	response.Success(c, "Kurir berhasil diperbarui", courier)
+}
+
+func (h *CourierHandler) DeleteCourier(c *gin.Context) {
+	id, ok := courierID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteCourier(c.Request.Context(), id); err != nil {
+		writeCourierError(c, "Gagal menghapus kurir", err)
+		return
+	}
+
+	response.Success(c, "Kurir berhasil dihapus", gin.H{"id": id})
+}
+
+func courierID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Format ID tidak valid")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeCourierError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrCourierNotFound):
+		response.Error(c, http.StatusNotFound, "Kurir tidak ditemukan")
+	case errors.Is(err, service.ErrInvalidCourier):
+		response.Error(c, http.StatusBadRequest, message+": "+err.Error())
+	case errors.Is(err, service.ErrCourierInUse):
+		response.Error(c, http.StatusConflict, message+": "+err.Error())
+	default:
+		response.Error(c, http.StatusInternalServerError, message+": "+err.Error())
+	}
+}
@@ -0,0 +1,93 @@
+package dellivery
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/service"
+	"github.com/purnama/Event-Driven-Logistic/pkg/response"
+)
+
+type LocationRequest struct {
+	Lat  *float64 `json:"lat" binding:"required"`
+	Long *float64 `json:"long" binding:"required"`
+	// RecordedAt adalah waktu fix direkam perangkat; kosong berarti sekarang.
+	RecordedAt *time.Time `json:"recorded_at"`
+}
+
+// LocationBatchRequest dibatasi 500 fix per request.
+type LocationBatchRequest struct {
+	Fixes []LocationRequest `json:"fixes" binding:"required,min=1,max=500,dive"`
+}
+
+func (r LocationRequest) fix() service.LocationFix {
+	fix := service.LocationFix{Lat: *r.Lat, Long: *r.Long}
+	if r.RecordedAt != nil {
+		fix.RecordedAt = *r.RecordedAt
+	}
+	return fix
+}
+
+func (h *ShipmentHandler) UpdateLocation(c *gin.Context) {
+	var req LocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Request tidak valid: "+err.Error())
+		return
+	}
+
+	h.recordLocations(c, []service.LocationFix{req.fix()})
+}
+
+// UpdateLocationBatch menerima buffer fix yang dikumpulkan perangkat kurir
+// selama offline.
+func (h *ShipmentHandler) UpdateLocationBatch(c *gin.Context) {
+	var req LocationBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Request tidak valid: "+err.Error())
+		return
+	}
+
+	fixes := make([]service.LocationFix, len(req.Fixes))
+	for i, r := range req.Fixes {
+		fixes[i] = r.fix()
+	}
+	h.recordLocations(c, fixes)
+}
+
+func (h *ShipmentHandler) recordLocations(c *gin.Context, fixes []service.LocationFix) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Format ID tidak valid")
+		return
+	}
+
+	stored, err := h.svc.RecordLocations(c.Request.Context(), uint(id), fixes)
+	if err != nil {
+		writeShipmentError(c, "Gagal mencatat lokasi", err)
+		return
+	}
+
+	response.Success(c, "Lokasi shipment berhasil dicatat", gin.H{
+		"shipment_id": id,
+		"received":    len(fixes),
+		"stored":      stored,
+	})
+}
+
+func writeShipmentError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrShipmentNotFound):
+		response.Error(c, http.StatusNotFound, "Shipment tidak ditemukan")
+	case errors.Is(err, service.ErrProofNotFound):
+		response.Error(c, http.StatusNotFound, message+": "+err.Error())
+	case errors.Is(err, service.ErrInvalidLocation), isProofError(err):
+		response.Error(c, http.StatusBadRequest, message+": "+err.Error())
+	case errors.Is(err, service.ErrNoCourierAssigned):
+		response.Error(c, http.StatusConflict, message+": "+err.Error())
+	default:
+		response.Error(c, http.StatusInternalServerError, message+": "+err.Error())
+	}
+}
@@ -0,0 +1,122 @@
+package dellivery
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/service"
+	"github.com/purnama/Event-Driven-Logistic/pkg/response"
+)
+
+// maxStatusRequestBytes membatasi body PATCH /shipments/:id/status: dua
+// lampiran dalam base64 (4/3 ukuran aslinya) ditambah 1 MB untuk field lain.
+const maxStatusRequestBytes = 2*service.MaxAttachmentBytes*4/3 + 1<<20
+
+// ProofRequest adalah bukti pengiriman pada PATCH /shipments/:id/status
+// dengan status DELIVERED. Signature dan Photo berisi gambar PNG/JPEG dalam
+// base64 (boleh berupa data URL).
+type ProofRequest struct {
+	RecipientName string     `json:"recipient_name" binding:"required"`
+	Signature     string     `json:"signature"`
+	Photo         string     `json:"photo"`
+	Lat           *float64   `json:"lat" binding:"required"`
+	Long          *float64   `json:"long" binding:"required"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+}
+
+// ProofResponse adalah bukti pengiriman beserta URL lampirannya.
+type ProofResponse struct {
+	*repository.DeliveryProof
+	SignatureURL string `json:"signature_url,omitempty"`
+	PhotoURL     string `json:"photo_url,omitempty"`
+}
+
+func (r *ProofRequest) proof() (*service.ProofOfDelivery, error) {
+	proof := &service.ProofOfDelivery{
+		RecipientName: r.RecipientName,
+		Lat:           *r.Lat,
+		Long:          *r.Long,
+	}
+	if r.DeliveredAt != nil {
+		proof.DeliveredAt = *r.DeliveredAt
+	}
+
+	var err error
+	if proof.Signature, err = decodeAttachment(service.AttachmentSignature, r.Signature); err != nil {
+		return nil, err
+	}
+	if proof.Photo, err = decodeAttachment(service.AttachmentPhoto, r.Photo); err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+// decodeAttachment mengubah base64 (atau data URL) menjadi lampiran; content
+// type diambil dari isi file, bukan dari client.
+func decodeAttachment(kind, encoded string) (*service.Attachment, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	if i := strings.Index(encoded, ";base64,"); strings.HasPrefix(encoded, "data:") && i >= 0 {
+		encoded = encoded[i+len(";base64,"):]
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s bukan base64 yang valid", service.ErrInvalidProof, kind)
+	}
+	return &service.Attachment{Data: data, ContentType: http.DetectContentType(data)}, nil
+}
+
+func (h *ShipmentHandler) GetDeliveryProof(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Format ID tidak valid")
+		return
+	}
+
+	proof, err := h.svc.GetDeliveryProof(uint(id))
+	if err != nil {
+		writeShipmentError(c, "Gagal mengambil bukti pengiriman", err)
+		return
+	}
+
+	resp := ProofResponse{DeliveryProof: proof}
+	if proof.SignatureKey != "" {
+		resp.SignatureURL = fmt.Sprintf("/shipments/%d/proof/%s", id, service.AttachmentSignature)
+	}
+	if proof.PhotoKey != "" {
+		resp.PhotoURL = fmt.Sprintf("/shipments/%d/proof/%s", id, service.AttachmentPhoto)
+	}
+	response.Success(c, "Bukti pengiriman ditemukan", resp)
+}
+
+// GetProofAttachment mengirim file tanda tangan atau foto bukti pengiriman.
+func (h *ShipmentHandler) GetProofAttachment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Format ID tidak valid")
+		return
+	}
+
+	r, contentType, err := h.svc.OpenProofAttachment(c.Request.Context(), uint(id), c.Param("kind"))
+	if err != nil {
+		writeShipmentError(c, "Gagal mengambil lampiran bukti pengiriman", err)
+		return
+	}
+	defer r.Close()
+
+	c.DataFromReader(http.StatusOK, -1, contentType, r, nil)
+}
+
+// isProofError bernilai true untuk error bukti pengiriman dari client.
+func isProofError(err error) bool {
+	return errors.Is(err, service.ErrProofRequired) || errors.Is(err, service.ErrInvalidProof)
+}
@@ -2,10 +2,24 @@ package dellivery
 
 import "github.com/gin-gonic/gin"
 
-func RegisterRoutes(router *gin.Engine, handler *ShipmentHandler) {
+func RegisterRoutes(router *gin.Engine, handler *ShipmentHandler, courierHandler *CourierHandler) {
 	shipments := router.Group("/shipments")
 	{
-		shipments.GET("/:order_id", handler.GetShipmentByOrderID)
+		// Semua route GET memakai wildcard :id; untuk GET /:id isinya order ID
+		shipments.GET("/:id", handler.GetShipmentByOrderID)
+		shipments.GET("/:id/proof", handler.GetDeliveryProof)
+		shipments.GET("/:id/proof/:kind", handler.GetProofAttachment)
 		shipments.PATCH("/:id/status", handler.UpdateShipmentStatus)
+		shipments.POST("/:id/location", handler.UpdateLocation)
+		shipments.POST("/:id/location/batch", handler.UpdateLocationBatch)
+	}
+
+	couriers := router.Group("/couriers")
+	{
+		couriers.GET("", courierHandler.ListCouriers)
+		couriers.GET("/:id", courierHandler.GetCourierByID)
+		couriers.POST("", courierHandler.CreateCourier)
+		couriers.PATCH("/:id", courierHandler.UpdateCourier)
+		couriers.DELETE("/:id", courierHandler.DeleteCourier)
 	}
 }
@@ -1,6 +1,8 @@
 package dellivery
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -18,8 +20,10 @@ func NewShipmentHandler(svc service.ShipmentService) *ShipmentHandler {
 	return &ShipmentHandler{svc: svc}
 }
 
+// GetShipmentByOrderID membaca order ID dari parameter :id, karena Gin
+// mewajibkan nama wildcard yang sama dengan GET /shipments/:id/proof.
 func (h *ShipmentHandler) GetShipmentByOrderID(c *gin.Context) {
-	orderID := c.Param("order_id")
+	orderID := c.Param("id")
 
 	if orderID == "" {
 		response.Error(c, http.StatusBadRequest, "order_id tidak boleh kosong")
@@ -37,6 +41,8 @@ func (h *ShipmentHandler) GetShipmentByOrderID(c *gin.Context) {
 
 type UpdateStatusRequest struct {
 	Status string `json:"status" binding:"required"`
+	// Proof wajib untuk status DELIVERED.
+	Proof *ProofRequest `json:"proof"`
 }
 
 func (h *ShipmentHandler) UpdateShipmentStatus(c *gin.Context) {
@@ -50,14 +56,30 @@ func (h *ShipmentHandler) UpdateShipmentStatus(c *gin.Context) {
 
 	var req UpdateStatusRequest
 
+	// Body dibatasi sebelum di-decode agar lampiran besar tidak dibaca utuh ke memori
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxStatusRequestBytes)
 	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.Error(c, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("Request lebih dari %d MB", maxStatusRequestBytes>>20))
+			return
+		}
 		response.Error(c, http.StatusBadRequest, "Request tidak valid: "+err.Error())
 		return
 	}
 
 	status := repository.ShipmentStatus(req.Status)
 
-	if err := h.svc.UpdateShipmentStatus(uint(id), status); err != nil {
+	var proof *service.ProofOfDelivery
+	if req.Proof != nil {
+		if proof, err = req.Proof.proof(); err != nil {
+			response.Error(c, http.StatusBadRequest, "Gagal update status: "+err.Error())
+			return
+		}
+	}
+
+	if err := h.svc.UpdateShipmentStatus(c.Request.Context(), uint(id), status, proof); err != nil {
 		response.Error(c, http.StatusBadRequest, "Gagal update status: "+err.Error())
 		return
 	}
@@ -0,0 +1,92 @@
+// Package eta memperkirakan jarak tersisa dan waktu tiba shipment.
+package eta
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+)
+
+const (
+	// SpeedWindow adalah rentang riwayat lokasi yang dipakai untuk menghitung
+	// kecepatan rata-rata kurir.
+	SpeedWindow = 15 * time.Minute
+
+	// MinSpeedKmh dan MaxSpeedKmh membatasi kecepatan dari riwayat lokasi agar
+	// kurir yang sedang berhenti atau fix GPS yang melompat tidak membuat ETA
+	// tak hingga atau terlalu optimis.
+	MinSpeedKmh = 5.0
+	MaxSpeedKmh = 120.0
+
+	// minSampleSpan adalah rentang waktu minimal riwayat lokasi agar
+	// kecepatan rata-rata dianggap cukup representatif.
+	minSampleSpan = time.Minute
+)
+
+// Estimator menghitung ETA dari jarak haversine dan kecepatan rata-rata kurir.
+type Estimator struct {
+	// DefaultSpeedKmh dipakai jika riwayat lokasi kurir belum cukup.
+	DefaultSpeedKmh float64
+}
+
+// Estimate adalah hasil perkiraan untuk satu rute.
+type Estimate struct {
+	DistanceKm float64
+	SpeedKmh   float64
+	ArrivalAt  time.Time
+}
+
+func New(defaultSpeedKmh float64) (Estimator, error) {
+	if defaultSpeedKmh <= 0 {
+		return Estimator{}, fmt.Errorf("kecepatan rata-rata default harus lebih dari 0: %f", defaultSpeedKmh)
+	}
+	return Estimator{DefaultSpeedKmh: defaultSpeedKmh}, nil
+}
+
+// Estimate menghitung jarak sepanjang route (misal posisi kurir → depot →
+// tujuan) dan waktu tiba dari now. recent adalah riwayat lokasi kurir dalam
+// SpeedWindow, urut dari yang paling lama.
+func (e Estimator) Estimate(now time.Time, recent []repository.ShipmentLocation, route ...geo.Point) Estimate {
+	var distance float64
+	for i := 1; i < len(route); i++ {
+		distance += geo.DistanceKm(route[i-1], route[i])
+	}
+
+	speed := e.DefaultSpeedKmh
+	if avg, ok := AverageSpeedKmh(recent); ok {
+		speed = math.Min(math.Max(avg, MinSpeedKmh), MaxSpeedKmh)
+	}
+
+	travel := time.Duration(distance / speed * float64(time.Hour))
+	return Estimate{
+		DistanceKm: distance,
+		SpeedKmh:   speed,
+		ArrivalAt:  now.Add(travel).Truncate(time.Second),
+	}
+}
+
+// AverageSpeedKmh menghitung kecepatan rata-rata dari fix yang urut waktu:
+// total jarak antar fix dibagi rentang waktu fix pertama sampai terakhir.
+// Mengembalikan false jika rentangnya kurang dari satu menit.
+func AverageSpeedKmh(fixes []repository.ShipmentLocation) (float64, bool) {
+	if len(fixes) < 2 {
+		return 0, false
+	}
+	span := fixes[len(fixes)-1].RecordedAt.Sub(fixes[0].RecordedAt)
+	if span < minSampleSpan {
+		return 0, false
+	}
+
+	var distance float64
+	for i := 1; i < len(fixes); i++ {
+		distance += geo.DistanceKm(point(fixes[i-1]), point(fixes[i]))
+	}
+	return distance / span.Hours(), true
+}
+
+func point(l repository.ShipmentLocation) geo.Point {
+	return geo.Point{Lat: l.Lat, Long: l.Long}
+}
@@ -0,0 +1,73 @@
+package eta
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+)
+
+var start = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// fix membuat lokasi di sepanjang ekuator; 0.1 derajat bujur ≈ 11.12 km.
+func fix(long float64, minutes int) repository.ShipmentLocation {
+	return repository.ShipmentLocation{Long: long, RecordedAt: start.Add(time.Duration(minutes) * time.Minute)}
+}
+
+func TestAverageSpeedKmh(t *testing.T) {
+	// 0.1 derajat dalam 20 menit ≈ 33.4 km/jam
+	speed, ok := AverageSpeedKmh([]repository.ShipmentLocation{fix(0, 0), fix(0.05, 10), fix(0.1, 20)})
+	if !ok {
+		t.Fatal("AverageSpeedKmh tidak menghasilkan kecepatan")
+	}
+	if math.Abs(speed-33.36) > 0.1 {
+		t.Errorf("speed = %.2f, want ≈33.36", speed)
+	}
+
+	if _, ok := AverageSpeedKmh([]repository.ShipmentLocation{fix(0, 0)}); ok {
+		t.Error("satu fix seharusnya tidak cukup")
+	}
+	short := []repository.ShipmentLocation{fix(0, 0), {Long: 0.01, RecordedAt: start.Add(30 * time.Second)}}
+	if _, ok := AverageSpeedKmh(short); ok {
+		t.Error("rentang kurang dari satu menit seharusnya tidak cukup")
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	e, err := New(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := []geo.Point{{Long: 0}, {Long: 0.1}, {Long: 0.2}} // ≈22.24 km
+
+	got := e.Estimate(start, nil, route...)
+	if got.SpeedKmh != 20 {
+		t.Errorf("tanpa riwayat speed = %.2f, want default 20", got.SpeedKmh)
+	}
+	if math.Abs(got.DistanceKm-22.24) > 0.01 {
+		t.Errorf("distance = %.2f, want ≈22.24", got.DistanceKm)
+	}
+	if want := start.Add(66*time.Minute + 43*time.Second); got.ArrivalAt.Sub(want).Abs() > 5*time.Second {
+		t.Errorf("arrival = %s, want ≈%s", got.ArrivalAt, want)
+	}
+
+	// Kurir diam: kecepatan dibatasi MinSpeedKmh
+	stopped := []repository.ShipmentLocation{fix(0, 0), fix(0, 10)}
+	if got := e.Estimate(start, stopped, route...); got.SpeedKmh != MinSpeedKmh {
+		t.Errorf("kurir diam speed = %.2f, want %.0f", got.SpeedKmh, MinSpeedKmh)
+	}
+
+	// Fix GPS melompat: kecepatan dibatasi MaxSpeedKmh
+	jump := []repository.ShipmentLocation{fix(0, 0), fix(5, 2)}
+	if got := e.Estimate(start, jump, route...); got.SpeedKmh != MaxSpeedKmh {
+		t.Errorf("fix melompat speed = %.2f, want %.0f", got.SpeedKmh, MaxSpeedKmh)
+	}
+}
+
+func TestNewRejectsNonPositiveSpeed(t *testing.T) {
+	if _, err := New(0); err == nil {
+		t.Error("New(0) seharusnya error")
+	}
+}
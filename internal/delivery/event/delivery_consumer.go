@@ -1,12 +1,16 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 
 	"github.com/google/uuid"
 	"github.com/purnama/Event-Driven-Logistic/internal/delivery/service"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
 )
 
 type DeliveryConsumer struct {
@@ -21,42 +25,82 @@ func NewDeliveryConsumer(consumer *broker.Consumer, svc service.ShipmentService)
 	}
 }
 func (dc *DeliveryConsumer) StartListening() error {
-	if err := dc.consumer.Subscribe(
+	if err := dc.consumer.SubscribeHandler(
 		"delivery.payment.success",
 		"payment.success",
 		dc.handlePaymentSuccess,
+		broker.DefaultSubscribeOptions(),
 	); err != nil {
 		return err
 	}
 
-	log.Println("✅ Delivery Consumer: listening for payment.success")
+	if err := dc.consumer.SubscribeHandler(
+		"delivery.order.created",
+		"order.created",
+		dc.handleOrderCreated,
+		broker.DefaultSubscribeOptions(),
+	); err != nil {
+		return err
+	}
+
+	log.Println("✅ Delivery Consumer: listening for payment.success, order.created")
 	return nil
 }
 
-func (dc *DeliveryConsumer) handlePaymentSuccess(event broker.Event) error {
+func (dc *DeliveryConsumer) handlePaymentSuccess(ctx context.Context, d *broker.Delivery) error {
+
 	var payload broker.PaymentSuccessPayload
-	if err := json.Unmarshal(event.Payload, &payload); err != nil {
-		log.Printf("❌ Gagal parse PaymentSuccessPayload: %v", err)
-		return err
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse PaymentSuccessPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	log.Printf("📨 Payment.success diterima di Delivery: OrderID=%s, Amount=%.2f",
+	correlation.Logf(ctx, "📨 Payment.success diterima di Delivery: OrderID=%s, Amount=%s",
 		payload.OrderID, payload.Amount)
 
 	orderID, err := uuid.Parse(payload.OrderID)
 	if err != nil {
-		log.Printf("❌ Format OrderID tidak valid: %s", payload.OrderID)
-		return err
+		correlation.Logf(ctx, "❌ Format OrderID tidak valid: %s", payload.OrderID)
+		return broker.Permanent(err)
 	}
 
-	shipment, err := dc.svc.CreateShipment(orderID, "Auto-Assigned")
+	shipment, err := dc.svc.CreateShipment(ctx, orderID)
 	if err != nil {
-		log.Printf("❌ Gagal membuat shipment: %v", err)
+		correlation.Logf(ctx, "❌ Gagal membuat shipment: %v", err)
 		return err
 	}
 
-	log.Printf("✅ Shipment dibuat: ShipmentID=%d, OrderID=%s, Status=%s",
+	correlation.Logf(ctx, "✅ Shipment dibuat: ShipmentID=%d, OrderID=%s, Status=%s",
 		shipment.ID, payload.OrderID, shipment.Status)
 
 	return nil
 }
+
+// handleOrderCreated menyimpan tujuan pengiriman order untuk shipment yang
+// dibuat setelah payment.success.
+func (dc *DeliveryConsumer) handleOrderCreated(ctx context.Context, d *broker.Delivery) error {
+
+	var payload broker.OrderCreatedPayload
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse OrderCreatedPayload: %v", err)
+		return broker.Permanent(err)
+	}
+
+	if payload.DeliveryAddress == nil {
+		correlation.Logf(ctx, "⏭️ Order.created tanpa alamat pengiriman, ETA tidak dihitung: OrderID=%s", payload.OrderID)
+		return nil
+	}
+
+	orderID, err := uuid.Parse(payload.OrderID)
+	if err != nil {
+		correlation.Logf(ctx, "❌ Format OrderID tidak valid: %s", payload.OrderID)
+		return broker.Permanent(err)
+	}
+
+	address := payload.DeliveryAddress
+	err = dc.svc.RecordDestination(ctx, orderID, address.Address, geo.Point{Lat: address.Lat, Long: address.Long})
+	if errors.Is(err, service.ErrInvalidLocation) {
+		return broker.Permanent(err)
+	}
+	return err
+}
@@ -3,18 +3,42 @@
 -- Note: Saat ini belum ada delivery-service di cmd/, tapi schema sudah disiapkan
 
 -- Create ENUM type for shipment status
-CREATE TYPE shipment_status AS ENUM ('PICKING_UP', 'ON_THE_WAY', 'DELIVERED', 'CANCELLED');
+CREATE TYPE shipment_status AS ENUM ('PENDING_ASSIGNMENT', 'PICKING_UP', 'ON_THE_WAY', 'ARRIVING', 'DELIVERED', 'CANCELLED');
+
+-- Create couriers table (kurir beserta kapasitas dan lokasi asal untuk assignment)
+CREATE TABLE IF NOT EXISTS couriers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    phone VARCHAR(30),
+    vehicle_type VARCHAR(20) NOT NULL,
+    capacity INTEGER NOT NULL DEFAULT 1 CHECK (capacity > 0),
+    available BOOLEAN NOT NULL DEFAULT TRUE,
+    home_lat DECIMAL(10, 8),
+    home_long DECIMAL(11, 8),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_couriers_available ON couriers(available);
 
 -- Create shipments table
 CREATE TABLE IF NOT EXISTS shipments (
     id SERIAL PRIMARY KEY,
     order_id UUID NOT NULL UNIQUE,
+    courier_id INTEGER REFERENCES couriers(id) ON DELETE RESTRICT,
     courier_name VARCHAR(255),
     current_lat FLOAT,
     current_long FLOAT,
+    destination_address TEXT,
     destination_lat FLOAT,
     destination_long FLOAT,
-    status shipment_status NOT NULL DEFAULT 'PICKING_UP',
+    estimated_arrival_at TIMESTAMP WITH TIME ZONE,
+    remaining_distance_km DECIMAL(10, 3),
+    published_arrival_at TIMESTAMP WITH TIME ZONE,
+    status shipment_status NOT NULL DEFAULT 'PENDING_ASSIGNMENT',
+    location_updated_at TIMESTAMP WITH TIME ZONE,
+    location_published_at TIMESTAMP WITH TIME ZONE,
+    pickup_entered_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
@@ -22,8 +46,46 @@ CREATE TABLE IF NOT EXISTS shipments (
 -- Create indexes
 CREATE INDEX idx_shipments_order_id ON shipments(order_id);
 CREATE INDEX idx_shipments_status ON shipments(status);
+CREATE INDEX idx_shipments_courier_id ON shipments(courier_id);
 CREATE INDEX idx_shipments_created_at ON shipments(created_at DESC);
 
+-- Create order_destinations table (tujuan dari order.created, disalin ke shipment saat dibuat)
+CREATE TABLE IF NOT EXISTS order_destinations (
+    order_id UUID PRIMARY KEY,
+    address TEXT NOT NULL,
+    lat DECIMAL(10, 8) NOT NULL,
+    long DECIMAL(11, 8) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create delivery_proofs table (bukti serah terima untuk status DELIVERED; file di blobstore)
+CREATE TABLE IF NOT EXISTS delivery_proofs (
+    id SERIAL PRIMARY KEY,
+    shipment_id INTEGER NOT NULL UNIQUE REFERENCES shipments(id) ON DELETE CASCADE,
+    recipient_name VARCHAR(255) NOT NULL,
+    signature_key TEXT,
+    signature_content_type VARCHAR(50),
+    photo_key TEXT,
+    photo_content_type VARCHAR(50),
+    lat DECIMAL(10, 8) NOT NULL,
+    long DECIMAL(11, 8) NOT NULL,
+    delivered_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create shipment_locations table (time-series semua fix GPS kurir, termasuk buffer offline)
+CREATE TABLE IF NOT EXISTS shipment_locations (
+    id BIGSERIAL PRIMARY KEY,
+    shipment_id INTEGER NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    lat DECIMAL(10, 8) NOT NULL,
+    long DECIMAL(11, 8) NOT NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Unique agar fix yang dikirim ulang (retry batch) tidak tersimpan dua kali
+CREATE UNIQUE INDEX idx_shipment_locations_shipment_recorded ON shipment_locations(shipment_id, recorded_at);
+
 -- Create trigger to update updated_at
 CREATE OR REPLACE FUNCTION update_updated_at_column()
 RETURNS TRIGGER AS $$
@@ -37,3 +99,36 @@ CREATE TRIGGER update_shipments_updated_at
     BEFORE UPDATE ON shipments
     FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_couriers_updated_at
+    BEFORE UPDATE ON couriers
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Create outbox table (transactional outbox untuk event shipment.*)
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id SERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    body TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE,
+    failed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Dipakai relay untuk mencari pesan yang belum terkirim; pesan rusak (failed_at) tidak ikut
+CREATE INDEX idx_outbox_messages_next_attempt_at ON outbox_messages(next_attempt_at) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_messages_published_at ON outbox_messages(published_at);
+
+-- Create processed_events table (event yang sudah diproses per queue, untuk idempotency consumer)
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(64) NOT NULL,
+    consumer VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, consumer)
+);
+
+CREATE INDEX idx_processed_events_processed_at ON processed_events(processed_at);
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CourierRepository interface {
+	CreateCourier(courier *Courier) error
+	GetCourierByID(id uint) (*Courier, error)
+	ListCouriers() ([]CourierLoad, error)
+	UpdateCourier(courier *Courier) error
+	DeleteCourier(id uint) error
+
+	// CountShipments menghitung semua shipment (aktif maupun selesai) milik kurir.
+	CountShipments(courierID uint) (int64, error)
+
+	// ListAssignable mengembalikan kurir yang tersedia dan belum mencapai kapasitas.
+	ListAssignable() ([]CourierLoad, error)
+
+	// LockCourierLoad mengunci baris kurir sampai transaksi selesai dan
+	// menghitung ulang beban aktifnya, agar dua assignment bersamaan tidak
+	// melebihi kapasitas kurir yang sama.
+	LockCourierLoad(id uint) (*CourierLoad, error)
+}
+
+type courierRepository struct {
+	db *gorm.DB
+}
+
+func NewCourierRepository(db *gorm.DB) CourierRepository {
+	return &courierRepository{db: db}
+}
+
+func (r *courierRepository) CreateCourier(courier *Courier) error {
+	return r.db.Create(courier).Error
+}
+
+func (r *courierRepository) GetCourierByID(id uint) (*Courier, error) {
+	var courier Courier
+	err := r.db.First(&courier, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &courier, nil
+}
+
+func (r *courierRepository) ListCouriers() ([]CourierLoad, error) {
+	var couriers []CourierLoad
+	err := r.withLoad().Order("couriers.id").Scan(&couriers).Error
+	if err != nil {
+		return nil, err
+	}
+	return couriers, nil
+}
+
+func (r *courierRepository) UpdateCourier(courier *Courier) error {
+	return r.db.Save(courier).Error
+}
+
+func (r *courierRepository) DeleteCourier(id uint) error {
+	return r.db.Delete(&Courier{}, id).Error
+}
+
+func (r *courierRepository) CountShipments(courierID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&Shipment{}).Where("courier_id = ?", courierID).Count(&count).Error
+	return count, err
+}
+
+func (r *courierRepository) ListAssignable() ([]CourierLoad, error) {
+	var couriers []CourierLoad
+	err := r.withLoad().
+		Where("couriers.available = ?", true).
+		Having("COUNT(shipments.id) < couriers.capacity").
+		Order("couriers.id").
+		Scan(&couriers).Error
+	if err != nil {
+		return nil, err
+	}
+	return couriers, nil
+}
+
+func (r *courierRepository) LockCourierLoad(id uint) (*CourierLoad, error) {
+	var courier Courier
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&courier, id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var active int64
+	err = r.db.Model(&Shipment{}).
+		Where("courier_id = ? AND status IN ?", id, ActiveShipmentStatuses).
+		Count(&active).Error
+	if err != nil {
+		return nil, err
+	}
+	return &CourierLoad{Courier: courier, ActiveShipments: int(active)}, nil
+}
+
+// withLoad menggabungkan kurir dengan jumlah shipment aktifnya.
+func (r *courierRepository) withLoad() *gorm.DB {
+	return r.db.Model(&Courier{}).
+		Select("couriers.*, COUNT(shipments.id) AS active_shipments").
+		Joins("LEFT JOIN shipments ON shipments.courier_id = couriers.id AND shipments.status IN ?", ActiveShipmentStatuses).
+		Group("couriers.id")
+}
@@ -5,20 +5,28 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
 	"gorm.io/gorm"
 )
 
 type ShipmentStatus string
 
 const (
-	ShipmentStatusPickingUp ShipmentStatus = "PICKING_UP"
-	ShipmentStatusOnTheWay  ShipmentStatus = "ON_THE_WAY"
-	ShipmentStatusDelivered ShipmentStatus = "DELIVERED"
+	// ShipmentStatusPendingAssignment berarti belum ada kurir yang tersedia.
+	ShipmentStatusPendingAssignment ShipmentStatus = "PENDING_ASSIGNMENT"
+	ShipmentStatusPickingUp         ShipmentStatus = "PICKING_UP"
+	ShipmentStatusOnTheWay          ShipmentStatus = "ON_THE_WAY"
+	ShipmentStatusArriving          ShipmentStatus = "ARRIVING" // kurir di radius tujuan, menunggu serah terima
+	ShipmentStatusDelivered         ShipmentStatus = "DELIVERED"
 )
 
+// ActiveShipmentStatuses adalah status shipment yang sedang dibawa kurir
+// dan dihitung sebagai beban kurir.
+var ActiveShipmentStatuses = []ShipmentStatus{ShipmentStatusPickingUp, ShipmentStatusOnTheWay, ShipmentStatusArriving}
+
 func (s ShipmentStatus) IsValid() bool {
 	switch s {
-	case ShipmentStatusPickingUp, ShipmentStatusOnTheWay, ShipmentStatusDelivered:
+	case ShipmentStatusPendingAssignment, ShipmentStatusPickingUp, ShipmentStatusOnTheWay, ShipmentStatusArriving, ShipmentStatusDelivered:
 		return true
 	}
 	return false
@@ -27,12 +35,71 @@ func (s ShipmentStatus) IsValid() bool {
 type Shipment struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
 	OrderID     uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	CourierID   *uint          `gorm:"index" json:"courier_id"`
+	Courier     *Courier       `gorm:"constraint:OnDelete:RESTRICT" json:"-"`
 	CourierName string         `gorm:"not null" json:"courier_name"`
 	CurrentLat  float64        `gorm:"type:decimal(10,8)" json:"current_lat"`
 	CurrentLong float64        `gorm:"type:decimal(11,8)" json:"current_long"`
-	Status      ShipmentStatus `gorm:"type:varchar(20);default:PICKING_UP;not null" json:"status"`
+	Status      ShipmentStatus `gorm:"type:varchar(20);default:PENDING_ASSIGNMENT;not null" json:"status"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"` 
+
+	// LocationUpdatedAt adalah waktu fix GPS terbaru yang menjadi posisi saat ini.
+	LocationUpdatedAt *time.Time `json:"location_updated_at,omitempty"`
+	// LocationPublishedAt adalah waktu shipment.location_updated terakhir dikirim (untuk throttling).
+	LocationPublishedAt *time.Time `json:"-"`
+	// PickupEnteredAt adalah waktu kurir pertama kali masuk geofence depot;
+	// shipment baru otomatis ON_THE_WAY setelah kurir keluar lagi dari sana.
+	PickupEnteredAt *time.Time `json:"pickup_entered_at,omitempty"`
+
+	// Destination* adalah tujuan pengiriman dari order.created; kosong jika
+	// event order belum diterima.
+	DestinationAddress string   `gorm:"type:text" json:"destination_address,omitempty"`
+	DestinationLat     *float64 `gorm:"type:decimal(10,8)" json:"destination_lat,omitempty"`
+	DestinationLong    *float64 `gorm:"type:decimal(11,8)" json:"destination_long,omitempty"`
+
+	// EstimatedArrivalAt dan RemainingDistanceKm kosong selama tujuan atau
+	// kurir belum diketahui, dan setelah shipment DELIVERED.
+	EstimatedArrivalAt  *time.Time `json:"estimated_arrival_at,omitempty"`
+	RemainingDistanceKm *float64   `gorm:"type:decimal(10,3)" json:"remaining_distance_km,omitempty"`
+	// PublishedArrivalAt adalah ETA terakhir yang dikirim lewat shipment.eta_changed.
+	PublishedArrivalAt *time.Time `json:"-"`
+}
+
+// Destination mengembalikan koordinat tujuan shipment jika sudah diketahui.
+func (s *Shipment) Destination() (geo.Point, bool) {
+	if s.DestinationLat == nil || s.DestinationLong == nil {
+		return geo.Point{}, false
+	}
+	return geo.Point{Lat: *s.DestinationLat, Long: *s.DestinationLong}, true
+}
+
+// DeliveryProof adalah bukti serah terima shipment yang wajib ada untuk
+// status DELIVERED. File tanda tangan dan foto disimpan di blobstore;
+// tabel ini hanya menyimpan key dan content type-nya.
+type DeliveryProof struct {
+	ID                   uint   `gorm:"primaryKey" json:"id"`
+	ShipmentID           uint   `gorm:"not null;uniqueIndex" json:"shipment_id"`
+	RecipientName        string `gorm:"not null" json:"recipient_name"`
+	SignatureKey         string `json:"-"`
+	SignatureContentType string `gorm:"type:varchar(50)" json:"-"`
+	PhotoKey             string `json:"-"`
+	PhotoContentType     string `gorm:"type:varchar(50)" json:"-"`
+	// Lat dan Long adalah posisi GPS kurir saat serah terima.
+	Lat         float64   `gorm:"type:decimal(10,8);not null" json:"lat"`
+	Long        float64   `gorm:"type:decimal(11,8);not null" json:"long"`
+	DeliveredAt time.Time `gorm:"not null" json:"delivered_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OrderDestination menyimpan tujuan pengiriman dari order.created sampai
+// shipment order tersebut dibuat (setelah payment.success).
+type OrderDestination struct {
+	OrderID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"order_id"`
+	Address   string    `gorm:"type:text;not null" json:"address"`
+	Lat       float64   `gorm:"type:decimal(10,8);not null" json:"lat"`
+	Long      float64   `gorm:"type:decimal(11,8);not null" json:"long"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 func (s *Shipment) BeforeCreate(tx *gorm.DB) (err error) {
@@ -56,3 +123,81 @@ func (s *Shipment) BeforeUpdate(tx *gorm.DB) (err error) {
 
 	return nil
 }
+
+// ShipmentLocation adalah satu fix GPS kurir untuk shipment (time-series).
+// Fix dengan waktu yang sama hanya disimpan sekali, sehingga buffer offline
+// yang dikirim ulang tidak menggandakan data.
+type ShipmentLocation struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ShipmentID uint      `gorm:"not null;uniqueIndex:idx_shipment_locations_shipment_recorded" json:"shipment_id"`
+	Lat        float64   `gorm:"type:decimal(10,8);not null" json:"lat"`
+	Long       float64   `gorm:"type:decimal(11,8);not null" json:"long"`
+	RecordedAt time.Time `gorm:"not null;uniqueIndex:idx_shipment_locations_shipment_recorded" json:"recorded_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (l *ShipmentLocation) BeforeCreate(tx *gorm.DB) (err error) {
+	if l.Lat < -90 || l.Lat > 90 {
+		return fmt.Errorf("invalid latitude: %f (must be between -90 and 90)", l.Lat)
+	}
+	if l.Long < -180 || l.Long > 180 {
+		return fmt.Errorf("invalid longitude: %f (must be between -180 and 180)", l.Long)
+	}
+
+	return nil
+}
+
+type VehicleType string
+
+const (
+	VehicleMotorcycle VehicleType = "MOTORCYCLE"
+	VehicleCar        VehicleType = "CAR"
+	VehicleVan        VehicleType = "VAN"
+	VehicleTruck      VehicleType = "TRUCK"
+)
+
+func (v VehicleType) IsValid() bool {
+	switch v {
+	case VehicleMotorcycle, VehicleCar, VehicleVan, VehicleTruck:
+		return true
+	}
+	return false
+}
+
+// Courier adalah kurir yang bisa ditugaskan ke shipment.
+type Courier struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	Name        string      `gorm:"not null" json:"name"`
+	Phone       string      `gorm:"type:varchar(30)" json:"phone"`
+	VehicleType VehicleType `gorm:"type:varchar(20);not null" json:"vehicle_type"`
+	// Capacity adalah jumlah maksimal shipment aktif yang dibawa bersamaan.
+	Capacity  int       `gorm:"not null;default:1;check:capacity > 0" json:"capacity"`
+	Available bool      `gorm:"not null;index" json:"available"`
+	HomeLat   float64   `gorm:"type:decimal(10,8)" json:"home_lat"`
+	HomeLong  float64   `gorm:"type:decimal(11,8)" json:"home_long"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (c *Courier) BeforeSave(tx *gorm.DB) (err error) {
+	if !c.VehicleType.IsValid() {
+		return fmt.Errorf("invalid vehicle type: %s", c.VehicleType)
+	}
+	if c.Capacity <= 0 {
+		return fmt.Errorf("courier capacity must be positive: %d", c.Capacity)
+	}
+	if c.HomeLat < -90 || c.HomeLat > 90 {
+		return fmt.Errorf("invalid latitude: %f (must be between -90 and 90)", c.HomeLat)
+	}
+	if c.HomeLong < -180 || c.HomeLong > 180 {
+		return fmt.Errorf("invalid longitude: %f (must be between -180 and 180)", c.HomeLong)
+	}
+
+	return nil
+}
+
+// CourierLoad adalah kurir beserta jumlah shipment aktif yang sedang dibawanya.
+type CourierLoad struct {
+	Courier         `gorm:"embedded"`
+	ActiveShipments int `json:"active_shipments"`
+}
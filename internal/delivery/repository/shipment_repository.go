@@ -1,18 +1,69 @@
 package repository
 
 import (
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ShipmentRepository interface {
 	CreateShipment(shipment *Shipment) error
 	GetShipmentByOrderID(orderID string) (*Shipment, error)
 	GetShipmentByID(id uint) (*Shipment, error)
+	// GetShipmentByIDForUpdate mengunci baris shipment sampai transaksi selesai.
+	GetShipmentByIDForUpdate(id uint) (*Shipment, error)
 	UpdateShipment(shipment *Shipment) error
 
-	UpdateLocation(shipmentID uint, lat, long float64) error
+	// UpdateLocation mengubah posisi saat ini shipment ke fix yang direkam pada recordedAt.
+	UpdateLocation(shipmentID uint, lat, long float64, recordedAt time.Time) error
+
+	// CreateLocations menyimpan fix GPS; fix yang sudah tersimpan dilewati.
+	// Mengembalikan jumlah fix yang benar-benar baru.
+	CreateLocations(locations []ShipmentLocation) (int64, error)
+
+	// MarkLocationPublished mencatat waktu shipment.location_updated terakhir dikirim.
+	MarkLocationPublished(shipmentID uint, publishedAt time.Time) error
+
+	// RecentLocations mengembalikan fix GPS shipment sejak since, urut dari yang paling lama.
+	RecentLocations(shipmentID uint, since time.Time) ([]ShipmentLocation, error)
+
+	// GetShipmentByOrderIDForUpdate mengunci baris shipment milik order sampai transaksi selesai.
+	GetShipmentByOrderIDForUpdate(orderID string) (*Shipment, error)
+
+	// SaveDestination menyimpan tujuan pengiriman order; tujuan yang sudah
+	// tersimpan (order.created terkirim ulang) tidak ditimpa.
+	SaveDestination(destination *OrderDestination) error
+	GetDestination(orderID string) (*OrderDestination, error)
+
+	// SetDestination menyalin tujuan pengiriman ke shipment.
+	SetDestination(shipmentID uint, destination *OrderDestination) error
+
+	// UpdateETA menyimpan perkiraan waktu tiba dan jarak tersisa; nil menghapusnya.
+	UpdateETA(shipmentID uint, arrivalAt *time.Time, remainingKm *float64) error
+
+	// MarkETAPublished mencatat ETA yang terakhir dikirim lewat shipment.eta_changed.
+	MarkETAPublished(shipmentID uint, arrivalAt time.Time) error
+
+	// MarkPickupEntered mencatat waktu kurir masuk geofence depot.
+	MarkPickupEntered(shipmentID uint, at time.Time) error
 
 	UpdateStatus(shipmentID uint, status ShipmentStatus) error
+
+	CreateDeliveryProof(proof *DeliveryProof) error
+	GetDeliveryProof(shipmentID uint) (*DeliveryProof, error)
+
+	// AssignCourier menugaskan kurir ke shipment dan mengubah statusnya ke PICKING_UP.
+	AssignCourier(shipmentID uint, courier *Courier) error
+
+	// LockPendingAssignment mengunci shipment PENDING_ASSIGNMENT terlama.
+	// Baris yang sedang dikunci replika lain dilewati (SKIP LOCKED).
+	LockPendingAssignment(limit int) ([]Shipment, error)
+
+	// Transaction menjalankan fn dalam satu transaksi DB; event yang ditulis
+	// ke outbox ikut ter-commit bersama perubahan shipment dan kurir.
+	Transaction(fn func(repo ShipmentRepository, couriers CourierRepository, outbox *broker.Outbox) error) error
 }
 
 type shipmentRepository struct {
@@ -45,17 +96,139 @@ func (r *shipmentRepository) GetShipmentByID(id uint) (*Shipment, error) {
 	return &shipment, nil
 }
 
+func (r *shipmentRepository) GetShipmentByIDForUpdate(id uint) (*Shipment, error) {
+	var shipment Shipment
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&shipment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
 func (r *shipmentRepository) UpdateShipment(shipment *Shipment) error {
 	return r.db.Save(shipment).Error
 }
 
-func (r *shipmentRepository) UpdateLocation(shipmentID uint, lat, long float64) error {
-	return r.db.Model(&Shipment{}).Where("id = ?", shipmentID).Updates(map[string]interface{}{
-		"current_lat":  lat,
-		"current_long": long,
+func (r *shipmentRepository) UpdateLocation(shipmentID uint, lat, long float64, recordedAt time.Time) error {
+	// UpdateColumns melewati BeforeUpdate; status shipment tidak berubah di sini
+	return r.db.Model(&Shipment{}).Where("id = ?", shipmentID).UpdateColumns(map[string]interface{}{
+		"current_lat":         lat,
+		"current_long":        long,
+		"location_updated_at": recordedAt,
+		"updated_at":          time.Now(),
 	}).Error
 }
 
+func (r *shipmentRepository) CreateLocations(locations []ShipmentLocation) (int64, error) {
+	if len(locations) == 0 {
+		return 0, nil
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&locations)
+	return result.RowsAffected, result.Error
+}
+
+func (r *shipmentRepository) MarkLocationPublished(shipmentID uint, publishedAt time.Time) error {
+	return r.db.Model(&Shipment{}).Where("id = ?", shipmentID).
+		UpdateColumn("location_published_at", publishedAt).Error
+}
+
+func (r *shipmentRepository) RecentLocations(shipmentID uint, since time.Time) ([]ShipmentLocation, error) {
+	var locations []ShipmentLocation
+	err := r.db.Where("shipment_id = ? AND recorded_at >= ?", shipmentID, since).
+		Order("recorded_at").
+		Find(&locations).Error
+	if err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+func (r *shipmentRepository) GetShipmentByOrderIDForUpdate(orderID string) (*Shipment, error) {
+	var shipment Shipment
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", orderID).First(&shipment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+func (r *shipmentRepository) SaveDestination(destination *OrderDestination) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(destination).Error
+}
+
+func (r *shipmentRepository) GetDestination(orderID string) (*OrderDestination, error) {
+	var destination OrderDestination
+	err := r.db.Where("order_id = ?", orderID).First(&destination).Error
+	if err != nil {
+		return nil, err
+	}
+	return &destination, nil
+}
+
+func (r *shipmentRepository) SetDestination(shipmentID uint, destination *OrderDestination) error {
+	return r.db.Model(&Shipment{}).Where("id = ?", shipmentID).UpdateColumns(map[string]interface{}{
+		"destination_address": destination.Address,
+		"destination_lat":     destination.Lat,
+		"destination_long":    destination.Long,
+		"updated_at":          time.Now(),
+	}).Error
+}
+
+func (r *shipmentRepository) UpdateETA(shipmentID uint, arrivalAt *time.Time, remainingKm *float64) error {
+	return r.db.Model(&Shipment{}).Where("id = ?", shipmentID).UpdateColumns(map[string]interface{}{
+		"estimated_arrival_at":  arrivalAt,
+		"remaining_distance_km": remainingKm,
+	}).Error
+}
+
+func (r *shipmentRepository) MarkETAPublished(shipmentID uint, arrivalAt time.Time) error {
+	return r.db.Model(&Shipment{}).Where("id = ?", shipmentID).
+		UpdateColumn("published_arrival_at", arrivalAt).Error
+}
+
+func (r *shipmentRepository) MarkPickupEntered(shipmentID uint, at time.Time) error {
+	return r.db.Model(&Shipment{}).Where("id = ?", shipmentID).
+		UpdateColumn("pickup_entered_at", at).Error
+}
+
 func (r *shipmentRepository) UpdateStatus(shipmentID uint, status ShipmentStatus) error {
-	return r.db.Model(&Shipment{}).Where("id = ?", shipmentID).Update("status", status).Error
+	// BeforeUpdate memvalidasi Status milik model, jadi model harus membawa status baru
+	return r.db.Model(&Shipment{Status: status}).Where("id = ?", shipmentID).Update("status", status).Error
+}
+
+func (r *shipmentRepository) CreateDeliveryProof(proof *DeliveryProof) error {
+	return r.db.Create(proof).Error
+}
+
+func (r *shipmentRepository) GetDeliveryProof(shipmentID uint) (*DeliveryProof, error) {
+	var proof DeliveryProof
+	err := r.db.Where("shipment_id = ?", shipmentID).First(&proof).Error
+	if err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+func (r *shipmentRepository) AssignCourier(shipmentID uint, courier *Courier) error {
+	return r.db.Model(&Shipment{Status: ShipmentStatusPickingUp}).Where("id = ?", shipmentID).Updates(map[string]interface{}{
+		"courier_id":   courier.ID,
+		"courier_name": courier.Name,
+		"status":       ShipmentStatusPickingUp,
+	}).Error
+}
+
+func (r *shipmentRepository) LockPendingAssignment(limit int) ([]Shipment, error) {
+	var shipments []Shipment
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ?", ShipmentStatusPendingAssignment).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&shipments).Error
+	return shipments, err
+}
+
+func (r *shipmentRepository) Transaction(fn func(repo ShipmentRepository, couriers CourierRepository, outbox *broker.Outbox) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&shipmentRepository{db: tx}, &courierRepository{db: tx}, broker.NewOutbox(tx))
+	})
 }
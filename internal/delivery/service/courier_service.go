@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+	"gorm.io/gorm"
+)
+
+type CourierService interface {
+	CreateCourier(ctx context.Context, req CreateCourierRequest) (*repository.Courier, error)
+
+	GetCourierByID(id uint) (*repository.Courier, error)
+
+	// ListCouriers mengembalikan semua kurir beserta jumlah shipment aktifnya.
+	ListCouriers() ([]repository.CourierLoad, error)
+
+	UpdateCourier(ctx context.Context, id uint, req UpdateCourierRequest) (*repository.Courier, error)
+
+	// DeleteCourier menghapus kurir yang belum pernah membawa shipment. Kurir
+	// dengan riwayat shipment cukup dinonaktifkan (available=false).
+	DeleteCourier(ctx context.Context, id uint) error
+}
+
+var (
+	ErrCourierNotFound = errors.New("kurir tidak ditemukan")
+
+	// ErrInvalidCourier dikembalikan jika data kurir tidak valid.
+	ErrInvalidCourier = errors.New("data kurir tidak valid")
+
+	// ErrCourierInUse dikembalikan saat menghapus kurir yang punya riwayat shipment.
+	ErrCourierInUse = errors.New("kurir masih tercatat di shipment")
+)
+
+type CreateCourierRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Phone       string `json:"phone"`
+	VehicleType string `json:"vehicle_type" binding:"required"`
+	Capacity    int    `json:"capacity" binding:"required,min=1"`
+	// Available default true jika tidak diisi.
+	Available *bool   `json:"available"`
+	HomeLat   float64 `json:"home_lat"`
+	HomeLong  float64 `json:"home_long"`
+}
+
+// UpdateCourierRequest hanya mengubah field yang diisi.
+type UpdateCourierRequest struct {
+	Name        *string  `json:"name"`
+	Phone       *string  `json:"phone"`
+	VehicleType *string  `json:"vehicle_type"`
+	Capacity    *int     `json:"capacity" binding:"omitempty,min=1"`
+	Available   *bool    `json:"available"`
+	HomeLat     *float64 `json:"home_lat"`
+	HomeLong    *float64 `json:"home_long"`
+}
+
+// Assigner menugaskan kurir ke shipment yang masih menunggu.
+type Assigner interface {
+	AssignPendingShipments(ctx context.Context) (int, error)
+}
+
+type courierService struct {
+	repo     repository.CourierRepository
+	assigner Assigner
+}
+
+func NewCourierService(repo repository.CourierRepository, assigner Assigner) CourierService {
+	return &courierService{repo: repo, assigner: assigner}
+}
+
+func (s *courierService) CreateCourier(ctx context.Context, req CreateCourierRequest) (*repository.Courier, error) {
+	courier := &repository.Courier{
+		Name:        req.Name,
+		Phone:       req.Phone,
+		VehicleType: repository.VehicleType(req.VehicleType),
+		Capacity:    req.Capacity,
+		Available:   req.Available == nil || *req.Available,
+		HomeLat:     req.HomeLat,
+		HomeLong:    req.HomeLong,
+	}
+	if err := validateCourier(courier); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateCourier(courier); err != nil {
+		correlation.Logf(ctx, "❌ Gagal membuat kurir: Nama=%s, error=%v", req.Name, err)
+		return nil, err
+	}
+
+	correlation.Logf(ctx, "✅ Kurir dibuat: ID=%d, Nama=%s, Kendaraan=%s, Kapasitas=%d",
+		courier.ID, courier.Name, courier.VehicleType, courier.Capacity)
+
+	s.assignPending(ctx, courier)
+	return courier, nil
+}
+
+func (s *courierService) GetCourierByID(id uint) (*repository.Courier, error) {
+	courier, err := s.repo.GetCourierByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: ID=%d", ErrCourierNotFound, id)
+	}
+	if err != nil {
+		log.Printf("❌ Gagal mengambil kurir: ID=%d, error=%v", id, err)
+		return nil, err
+	}
+	return courier, nil
+}
+
+func (s *courierService) ListCouriers() ([]repository.CourierLoad, error) {
+	return s.repo.ListCouriers()
+}
+
+func (s *courierService) UpdateCourier(ctx context.Context, id uint, req UpdateCourierRequest) (*repository.Courier, error) {
+	courier, err := s.GetCourierByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		courier.Name = *req.Name
+	}
+	if req.Phone != nil {
+		courier.Phone = *req.Phone
+	}
+	if req.VehicleType != nil {
+		courier.VehicleType = repository.VehicleType(*req.VehicleType)
+	}
+	if req.Capacity != nil {
+		courier.Capacity = *req.Capacity
+	}
+	if req.Available != nil {
+		courier.Available = *req.Available
+	}
+	if req.HomeLat != nil {
+		courier.HomeLat = *req.HomeLat
+	}
+	if req.HomeLong != nil {
+		courier.HomeLong = *req.HomeLong
+	}
+	if err := validateCourier(courier); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateCourier(courier); err != nil {
+		correlation.Logf(ctx, "❌ Gagal update kurir: ID=%d, error=%v", id, err)
+		return nil, err
+	}
+
+	correlation.Logf(ctx, "✅ Kurir diperbarui: ID=%d, Available=%v, Kapasitas=%d",
+		courier.ID, courier.Available, courier.Capacity)
+
+	s.assignPending(ctx, courier)
+	return courier, nil
+}
+
+func (s *courierService) DeleteCourier(ctx context.Context, id uint) error {
+	if _, err := s.GetCourierByID(id); err != nil {
+		return err
+	}
+
+	count, err := s.repo.CountShipments(id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: ID=%d punya %d shipment, nonaktifkan dengan available=false", ErrCourierInUse, id, count)
+	}
+
+	if err := s.repo.DeleteCourier(id); err != nil {
+		correlation.Logf(ctx, "❌ Gagal menghapus kurir: ID=%d, error=%v", id, err)
+		return err
+	}
+
+	correlation.Logf(ctx, "🗑️ Kurir dihapus: ID=%d", id)
+	return nil
+}
+
+// assignPending mencoba menugaskan shipment yang menunggu setelah ada kurir
+// baru atau kurir yang kembali tersedia. Kegagalan tidak membatalkan
+// perubahan kurir; shipment akan dicoba lagi pada assignment berikutnya.
+func (s *courierService) assignPending(ctx context.Context, courier *repository.Courier) {
+	if s.assigner == nil || !courier.Available {
+		return
+	}
+	if _, err := s.assigner.AssignPendingShipments(ctx); err != nil {
+		correlation.Logf(ctx, "⚠️ Gagal menugaskan shipment yang menunggu kurir: %v", err)
+	}
+}
+
+func validateCourier(courier *repository.Courier) error {
+	if courier.Name == "" {
+		return fmt.Errorf("%w: nama kurir tidak boleh kosong", ErrInvalidCourier)
+	}
+	if !courier.VehicleType.IsValid() {
+		return fmt.Errorf("%w: jenis kendaraan %q (pilih MOTORCYCLE, CAR, VAN atau TRUCK)", ErrInvalidCourier, courier.VehicleType)
+	}
+	if courier.Capacity <= 0 {
+		return fmt.Errorf("%w: kapasitas harus lebih dari 0", ErrInvalidCourier)
+	}
+	if err := (geo.Point{Lat: courier.HomeLat, Long: courier.HomeLong}).Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCourier, err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/eta"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+	"gorm.io/gorm"
+)
+
+func (s *shipmentService) RecordDestination(ctx context.Context, orderID uuid.UUID, address string, point geo.Point) error {
+	if err := point.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidLocation, err)
+	}
+
+	destination := &repository.OrderDestination{
+		OrderID: orderID,
+		Address: address,
+		Lat:     point.Lat,
+		Long:    point.Long,
+	}
+
+	var shipmentID uint
+	err := s.repo.Transaction(func(repo repository.ShipmentRepository, _ repository.CourierRepository, outbox *broker.Outbox) error {
+		if err := repo.SaveDestination(destination); err != nil {
+			return err
+		}
+
+		// Biasanya shipment belum ada (dibuat setelah payment.success); jika
+		// order.created terlambat diproses, tujuan langsung disalin ke shipment
+		shipment, err := repo.GetShipmentByOrderIDForUpdate(orderID.String())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := shipment.Destination(); ok {
+			return nil
+		}
+
+		if err := repo.SetDestination(shipment.ID, destination); err != nil {
+			return err
+		}
+		shipment.DestinationAddress = destination.Address
+		shipment.DestinationLat = &destination.Lat
+		shipment.DestinationLong = &destination.Long
+		shipmentID = shipment.ID
+
+		return s.refreshETA(ctx, repo, outbox, shipment, time.Now())
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal menyimpan tujuan pengiriman: OrderID=%s, error=%v", orderID, err)
+		return err
+	}
+
+	if shipmentID != 0 {
+		correlation.Logf(ctx, "🏠 Tujuan pengiriman disalin ke shipment: ID=%d, OrderID=%s", shipmentID, orderID)
+	} else {
+		correlation.Logf(ctx, "🏠 Tujuan pengiriman disimpan: OrderID=%s, Lat=%f, Long=%f", orderID, point.Lat, point.Long)
+	}
+	return nil
+}
+
+// refreshETA menghitung ulang ETA shipment dari status, posisi dan tujuan
+// terbarunya (shipment sudah berisi perubahan dalam transaksi yang sama).
+// shipment.eta_changed hanya dikirim untuk ETA pertama atau jika ETA
+// bergeser minimal ETAChangeThreshold dari ETA yang terakhir dikirim.
+func (s *shipmentService) refreshETA(ctx context.Context, repo repository.ShipmentRepository, outbox *broker.Outbox, shipment *repository.Shipment, now time.Time) error {
+	route, ok := s.route(shipment)
+	if !ok {
+		if shipment.EstimatedArrivalAt == nil {
+			return nil
+		}
+		shipment.EstimatedArrivalAt, shipment.RemainingDistanceKm = nil, nil
+		return repo.UpdateETA(shipment.ID, nil, nil)
+	}
+
+	recent, err := repo.RecentLocations(shipment.ID, now.Add(-eta.SpeedWindow))
+	if err != nil {
+		return err
+	}
+	estimate := s.cfg.ETA.Estimate(now, recent, route...)
+	if err := repo.UpdateETA(shipment.ID, &estimate.ArrivalAt, &estimate.DistanceKm); err != nil {
+		return err
+	}
+	shipment.EstimatedArrivalAt, shipment.RemainingDistanceKm = &estimate.ArrivalAt, &estimate.DistanceKm
+
+	previous := shipment.PublishedArrivalAt
+	if previous != nil && estimate.ArrivalAt.Sub(*previous).Abs() < s.cfg.ETAChangeThreshold {
+		return nil
+	}
+	if err := repo.MarkETAPublished(shipment.ID, estimate.ArrivalAt); err != nil {
+		return err
+	}
+	shipment.PublishedArrivalAt = &estimate.ArrivalAt
+
+	correlation.Logf(ctx, "⏱️ ETA shipment berubah: ID=%d, Tiba=%s, Jarak=%.2f km, Kecepatan=%.1f km/jam",
+		shipment.ID, estimate.ArrivalAt.Format(time.RFC3339), estimate.DistanceKm, estimate.SpeedKmh)
+
+	return outbox.EnqueueEventWithContext(ctx, broker.ShipmentETAChanged, broker.ShipmentETAChangedPayload{
+		ShipmentID:          shipment.ID,
+		OrderID:             shipment.OrderID.String(),
+		EstimatedArrivalAt:  estimate.ArrivalAt,
+		PreviousArrivalAt:   previous,
+		RemainingDistanceKm: estimate.DistanceKm,
+		SpeedKmh:            estimate.SpeedKmh,
+	})
+}
+
+// route mengembalikan titik-titik yang masih harus dilalui kurir. Saat
+// PICKING_UP kurir harus ke depot dulu; tanpa fix GPS posisi kurir dianggap
+// di depot. ETA hanya dihitung untuk shipment aktif yang tujuannya diketahui.
+func (s *shipmentService) route(shipment *repository.Shipment) ([]geo.Point, bool) {
+	destination, ok := shipment.Destination()
+	if !ok {
+		return nil, false
+	}
+
+	current := s.cfg.Depot
+	if shipment.LocationUpdatedAt != nil {
+		current = geo.Point{Lat: shipment.CurrentLat, Long: shipment.CurrentLong}
+	}
+
+	switch shipment.Status {
+	case repository.ShipmentStatusPickingUp:
+		return []geo.Point{current, s.cfg.Depot, destination}, true
+	case repository.ShipmentStatusOnTheWay, repository.ShipmentStatusArriving:
+		return []geo.Point{current, destination}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+)
+
+// geofence menentukan transisi status otomatis dari posisi kurir: keluar dari
+// radius depot setelah menjemput paket (PICKING_UP → ON_THE_WAY) dan masuk
+// radius tujuan (ON_THE_WAY → ARRIVING).
+type geofence struct {
+	pickup         geo.Point
+	pickupKm       float64
+	destination    geo.Point
+	hasDestination bool
+	destinationKm  float64
+}
+
+// statusChange adalah satu transisi status otomatis, untuk dicatat di log.
+type statusChange struct {
+	from, to repository.ShipmentStatus
+	at       time.Time
+}
+
+// step mengembalikan status shipment setelah fix p. entered bernilai true jika
+// fix ini adalah saat kurir pertama kali masuk radius depot; kurir yang
+// belum pernah masuk depot masih dalam perjalanan menjemput paket.
+func (g geofence) step(status repository.ShipmentStatus, pickupEntered bool, p geo.Point) (next repository.ShipmentStatus, entered bool) {
+	switch status {
+	case repository.ShipmentStatusPickingUp:
+		inside := geo.DistanceKm(p, g.pickup) <= g.pickupKm
+		if !pickupEntered {
+			return status, inside
+		}
+		if !inside {
+			return repository.ShipmentStatusOnTheWay, false
+		}
+	case repository.ShipmentStatusOnTheWay:
+		if g.hasDestination && geo.DistanceKm(p, g.destination) <= g.destinationKm {
+			return repository.ShipmentStatusArriving, false
+		}
+	}
+	return status, false
+}
+
+func (s *shipmentService) geofence(shipment *repository.Shipment) geofence {
+	destination, ok := shipment.Destination()
+	return geofence{
+		pickup:         s.cfg.Depot,
+		pickupKm:       s.cfg.PickupGeofenceKm,
+		destination:    destination,
+		hasDestination: ok,
+		destinationKm:  s.cfg.DestinationGeofenceKm,
+	}
+}
+
+// applyGeofence menjalankan fixes (urut waktu, lebih baru dari posisi saat
+// ini) melalui geofence dan menyimpan setiap transisi otomatis yang diizinkan
+// tabel transisi, masing-masing dengan event shipment.status_updated.
+// shipment diperbarui sesuai status terakhir.
+func (s *shipmentService) applyGeofence(ctx context.Context, repo repository.ShipmentRepository, outbox *broker.Outbox, shipment *repository.Shipment, fixes []LocationFix) ([]statusChange, error) {
+	g := s.geofence(shipment)
+
+	var changes []statusChange
+	for _, fix := range fixes {
+		next, entered := g.step(shipment.Status, shipment.PickupEnteredAt != nil, geo.Point{Lat: fix.Lat, Long: fix.Long})
+		if entered {
+			at := fix.RecordedAt
+			if err := repo.MarkPickupEntered(shipment.ID, at); err != nil {
+				return nil, err
+			}
+			shipment.PickupEnteredAt = &at
+		}
+		if next == shipment.Status || !isValidStatusTransition(shipment.Status, next) {
+			continue
+		}
+
+		if err := repo.UpdateStatus(shipment.ID, next); err != nil {
+			return nil, err
+		}
+		err := outbox.EnqueueEventWithContext(ctx, broker.ShipmentStatusUpdated, broker.ShipmentStatusUpdatedPayload{
+			ShipmentID: shipment.ID,
+			OrderID:    shipment.OrderID.String(),
+			CourierID:  shipment.CourierID,
+			FromStatus: string(shipment.Status),
+			ToStatus:   string(next),
+			Automatic:  true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, statusChange{from: shipment.Status, to: next, at: fix.RecordedAt})
+		shipment.Status = next
+	}
+	return changes, nil
+}
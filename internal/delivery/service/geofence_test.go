@@ -0,0 +1,77 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+)
+
+func TestGeofenceStep(t *testing.T) {
+	g := geofence{
+		pickup:         geo.Point{Lat: -6.2, Long: 106.8},
+		pickupKm:       0.2,
+		destination:    geo.Point{Lat: -6.3, Long: 106.9},
+		hasDestination: true,
+		destinationKm:  0.3,
+	}
+	atDepot := geo.Point{Lat: -6.2005, Long: 106.8}        // ±55 m dari depot
+	leftDepot := geo.Point{Lat: -6.21, Long: 106.8}        // ±1.1 km dari depot
+	nearDestination := geo.Point{Lat: -6.301, Long: 106.9} // ±110 m dari tujuan
+
+	tests := []struct {
+		name          string
+		status        repository.ShipmentStatus
+		pickupEntered bool
+		point         geo.Point
+		want          repository.ShipmentStatus
+		wantEntered   bool
+	}{
+		{"menuju depot", repository.ShipmentStatusPickingUp, false, leftDepot, repository.ShipmentStatusPickingUp, false},
+		{"masuk depot", repository.ShipmentStatusPickingUp, false, atDepot, repository.ShipmentStatusPickingUp, true},
+		{"masih di depot", repository.ShipmentStatusPickingUp, true, atDepot, repository.ShipmentStatusPickingUp, false},
+		{"keluar depot", repository.ShipmentStatusPickingUp, true, leftDepot, repository.ShipmentStatusOnTheWay, false},
+		{"dalam perjalanan", repository.ShipmentStatusOnTheWay, true, leftDepot, repository.ShipmentStatusOnTheWay, false},
+		{"masuk radius tujuan", repository.ShipmentStatusOnTheWay, true, nearDestination, repository.ShipmentStatusArriving, false},
+		{"sudah arriving", repository.ShipmentStatusArriving, true, nearDestination, repository.ShipmentStatusArriving, false},
+	}
+
+	for _, tt := range tests {
+		got, entered := g.step(tt.status, tt.pickupEntered, tt.point)
+		if got != tt.want || entered != tt.wantEntered {
+			t.Errorf("%s: step = (%s, %v), want (%s, %v)", tt.name, got, entered, tt.want, tt.wantEntered)
+		}
+	}
+
+	// Tanpa tujuan, shipment tidak pernah otomatis ARRIVING
+	g.hasDestination = false
+	if got, _ := g.step(repository.ShipmentStatusOnTheWay, true, nearDestination); got != repository.ShipmentStatusOnTheWay {
+		t.Errorf("tanpa tujuan: step = %s, want ON_THE_WAY", got)
+	}
+}
+
+func TestIsValidStatusTransition(t *testing.T) {
+	valid := [][2]repository.ShipmentStatus{
+		{repository.ShipmentStatusPendingAssignment, repository.ShipmentStatusPickingUp},
+		{repository.ShipmentStatusPickingUp, repository.ShipmentStatusOnTheWay},
+		{repository.ShipmentStatusOnTheWay, repository.ShipmentStatusArriving},
+		{repository.ShipmentStatusOnTheWay, repository.ShipmentStatusDelivered},
+		{repository.ShipmentStatusArriving, repository.ShipmentStatusDelivered},
+	}
+	for _, tr := range valid {
+		if !isValidStatusTransition(tr[0], tr[1]) {
+			t.Errorf("%s → %s seharusnya valid", tr[0], tr[1])
+		}
+	}
+
+	invalid := [][2]repository.ShipmentStatus{
+		{repository.ShipmentStatusPickingUp, repository.ShipmentStatusArriving},
+		{repository.ShipmentStatusArriving, repository.ShipmentStatusOnTheWay},
+		{repository.ShipmentStatusDelivered, repository.ShipmentStatusArriving},
+	}
+	for _, tr := range invalid {
+		if isValidStatusTransition(tr[0], tr[1]) {
+			t.Errorf("%s → %s seharusnya tidak valid", tr[0], tr[1])
+		}
+	}
+}
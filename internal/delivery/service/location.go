@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+	"gorm.io/gorm"
+)
+
+// maxClockSkew adalah toleransi jam perangkat kurir yang lebih cepat dari server.
+const maxClockSkew = 5 * time.Minute
+
+// ErrInvalidLocation dikembalikan jika fix GPS tidak valid.
+var ErrInvalidLocation = errors.New("lokasi tidak valid")
+
+// LocationFix adalah satu posisi GPS kurir. RecordedAt kosong berarti
+// direkam saat diterima server.
+type LocationFix struct {
+	Lat        float64   `json:"lat"`
+	Long       float64   `json:"long"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+func (s *shipmentService) UpdateLocation(ctx context.Context, shipmentID uint, lat, long float64) error {
+	_, err := s.RecordLocations(ctx, shipmentID, []LocationFix{{Lat: lat, Long: long}})
+	return err
+}
+
+func (s *shipmentService) RecordLocations(ctx context.Context, shipmentID uint, fixes []LocationFix) (int, error) {
+	if len(fixes) == 0 {
+		return 0, fmt.Errorf("%w: tidak ada fix yang dikirim", ErrInvalidLocation)
+	}
+
+	now := time.Now()
+	fixes = append([]LocationFix(nil), fixes...)
+	for i := range fixes {
+		if fixes[i].RecordedAt.IsZero() {
+			fixes[i].RecordedAt = now
+		}
+		if err := (geo.Point{Lat: fixes[i].Lat, Long: fixes[i].Long}).Validate(); err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidLocation, err)
+		}
+		if fixes[i].RecordedAt.After(now.Add(maxClockSkew)) {
+			return 0, fmt.Errorf("%w: recorded_at %s ada di masa depan", ErrInvalidLocation, fixes[i].RecordedAt.Format(time.RFC3339))
+		}
+	}
+	// Buffer offline bisa datang tidak berurutan; fix terakhir adalah posisi terbaru
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].RecordedAt.Before(fixes[j].RecordedAt) })
+	latest := fixes[len(fixes)-1]
+
+	var (
+		stored    int64
+		moved     bool
+		published bool
+		changes   []statusChange
+	)
+	err := s.repo.Transaction(func(repo repository.ShipmentRepository, _ repository.CourierRepository, outbox *broker.Outbox) error {
+		shipment, err := repo.GetShipmentByIDForUpdate(shipmentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: ID=%d", ErrShipmentNotFound, shipmentID)
+		}
+		if err != nil {
+			return err
+		}
+		if shipment.CourierID == nil {
+			return fmt.Errorf("%w: ID=%d", ErrNoCourierAssigned, shipmentID)
+		}
+
+		locations := make([]repository.ShipmentLocation, len(fixes))
+		for i, fix := range fixes {
+			locations[i] = repository.ShipmentLocation{
+				ShipmentID: shipmentID,
+				Lat:        fix.Lat,
+				Long:       fix.Long,
+				RecordedAt: fix.RecordedAt,
+			}
+		}
+		if stored, err = repo.CreateLocations(locations); err != nil {
+			return err
+		}
+
+		// Fix yang lebih lama dari posisi saat ini hanya disimpan sebagai riwayat,
+		// begitu juga fix untuk shipment yang sudah tidak dalam perjalanan
+		if !isActiveShipment(shipment.Status) {
+			return nil
+		}
+		fresh := newerThan(fixes, shipment.LocationUpdatedAt)
+		if len(fresh) == 0 {
+			return nil
+		}
+
+		if changes, err = s.applyGeofence(ctx, repo, outbox, shipment, fresh); err != nil {
+			return err
+		}
+
+		if err := repo.UpdateLocation(shipmentID, latest.Lat, latest.Long, latest.RecordedAt); err != nil {
+			return err
+		}
+		moved = true
+
+		shipment.CurrentLat, shipment.CurrentLong = latest.Lat, latest.Long
+		shipment.LocationUpdatedAt = &latest.RecordedAt
+		if err := s.refreshETA(ctx, repo, outbox, shipment, now); err != nil {
+			return err
+		}
+
+		// Throttling: paling banyak satu event per LocationEventInterval per shipment
+		if shipment.LocationPublishedAt != nil && now.Sub(*shipment.LocationPublishedAt) < s.cfg.LocationEventInterval {
+			return nil
+		}
+		if err := repo.MarkLocationPublished(shipmentID, now); err != nil {
+			return err
+		}
+		published = true
+		return outbox.EnqueueEventWithContext(ctx, broker.ShipmentLocationUpdated, broker.ShipmentLocationUpdatedPayload{
+			ShipmentID: shipmentID,
+			OrderID:    shipment.OrderID.String(),
+			Lat:        latest.Lat,
+			Long:       latest.Long,
+			RecordedAt: latest.RecordedAt,
+		})
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal mencatat lokasi shipment: ID=%d, error=%v", shipmentID, err)
+		return 0, err
+	}
+
+	for _, c := range changes {
+		correlation.Logf(ctx, "🤖 Status shipment berubah otomatis (geofence): ID=%d, %s → %s, Fix=%s",
+			shipmentID, c.from, c.to, c.at.Format(time.RFC3339))
+	}
+	if moved {
+		correlation.Logf(ctx, "📍 Lokasi kurir diperbarui: ShipmentID=%d, Lat=%f, Long=%f, Fix=%d/%d baru, Event=%v",
+			shipmentID, latest.Lat, latest.Long, stored, len(fixes), published)
+	} else {
+		correlation.Logf(ctx, "📍 Riwayat lokasi disimpan: ShipmentID=%d, Fix=%d/%d baru",
+			shipmentID, stored, len(fixes))
+	}
+
+	return int(stored), nil
+}
+
+// newerThan mengembalikan fix yang direkam setelah since; fixes harus urut waktu.
+func newerThan(fixes []LocationFix, since *time.Time) []LocationFix {
+	if since == nil {
+		return fixes
+	}
+	for i, fix := range fixes {
+		if fix.RecordedAt.After(*since) {
+			return fixes[i:]
+		}
+	}
+	return nil
+}
+
+// isActiveShipment bernilai true jika shipment sedang dibawa kurir.
+func isActiveShipment(status repository.ShipmentStatus) bool {
+	for _, s := range repository.ActiveShipmentStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
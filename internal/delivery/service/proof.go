@@ -0,0 +1,181 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+	"gorm.io/gorm"
+)
+
+// Jenis lampiran bukti pengiriman.
+const (
+	AttachmentSignature = "signature"
+	AttachmentPhoto     = "photo"
+)
+
+// MaxAttachmentBytes adalah ukuran maksimal satu lampiran bukti pengiriman.
+const MaxAttachmentBytes = 5 << 20
+
+// attachmentExtensions adalah content type lampiran yang diterima.
+var attachmentExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+}
+
+var (
+	// ErrProofRequired dikembalikan jika DELIVERED diminta tanpa bukti pengiriman.
+	ErrProofRequired = errors.New("status DELIVERED wajib disertai bukti pengiriman")
+
+	ErrInvalidProof = errors.New("bukti pengiriman tidak valid")
+
+	ErrProofNotFound = errors.New("bukti pengiriman tidak ditemukan")
+)
+
+// ProofOfDelivery adalah bukti serah terima yang dikirim kurir bersama
+// status DELIVERED. Tanda tangan dan foto opsional.
+type ProofOfDelivery struct {
+	RecipientName string
+	Signature     *Attachment
+	Photo         *Attachment
+	Lat           float64
+	Long          float64
+	// DeliveredAt kosong berarti waktu server saat bukti diterima.
+	DeliveredAt time.Time
+}
+
+// Attachment adalah satu file gambar lampiran bukti pengiriman.
+type Attachment struct {
+	Data        []byte
+	ContentType string
+}
+
+func (s *shipmentService) GetDeliveryProof(shipmentID uint) (*repository.DeliveryProof, error) {
+	proof, err := s.repo.GetDeliveryProof(shipmentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: ShipmentID=%d", ErrProofNotFound, shipmentID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return proof, nil
+}
+
+func (s *shipmentService) OpenProofAttachment(ctx context.Context, shipmentID uint, kind string) (io.ReadCloser, string, error) {
+	proof, err := s.GetDeliveryProof(shipmentID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var key, contentType string
+	switch kind {
+	case AttachmentSignature:
+		key, contentType = proof.SignatureKey, proof.SignatureContentType
+	case AttachmentPhoto:
+		key, contentType = proof.PhotoKey, proof.PhotoContentType
+	default:
+		return nil, "", fmt.Errorf("%w: lampiran %q tidak dikenal", ErrInvalidProof, kind)
+	}
+	if key == "" {
+		return nil, "", fmt.Errorf("%w: ShipmentID=%d tidak memiliki %s", ErrProofNotFound, shipmentID, kind)
+	}
+
+	r, err := s.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return r, contentType, nil
+}
+
+// storeProof memvalidasi bukti pengiriman dan mengunggah lampirannya ke
+// blobstore. Key blob unik per unggahan, sehingga lampiran bukti yang sudah
+// tersimpan tidak pernah tertimpa dan unggahan yang gagal aman dihapus.
+func (s *shipmentService) storeProof(ctx context.Context, shipmentID uint, p *ProofOfDelivery) (*repository.DeliveryProof, error) {
+	proof, err := newDeliveryProof(shipmentID, p, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("proofs/%d/%d-", shipmentID, time.Now().UnixNano())
+	for _, a := range []struct {
+		kind        string
+		attachment  *Attachment
+		key         *string
+		contentType *string
+	}{
+		{AttachmentSignature, p.Signature, &proof.SignatureKey, &proof.SignatureContentType},
+		{AttachmentPhoto, p.Photo, &proof.PhotoKey, &proof.PhotoContentType},
+	} {
+		if a.attachment == nil {
+			continue
+		}
+		key := prefix + a.kind + attachmentExtensions[a.attachment.ContentType]
+		if err := s.blobs.Put(ctx, key, bytes.NewReader(a.attachment.Data)); err != nil {
+			s.deleteProofBlobs(ctx, proof)
+			return nil, fmt.Errorf("gagal menyimpan %s bukti pengiriman: %w", a.kind, err)
+		}
+		*a.key, *a.contentType = key, a.attachment.ContentType
+	}
+	return proof, nil
+}
+
+// deleteProofBlobs menghapus lampiran bukti yang batal disimpan. Kegagalan
+// hanya dicatat; file yatim tidak memengaruhi data shipment.
+func (s *shipmentService) deleteProofBlobs(ctx context.Context, proof *repository.DeliveryProof) {
+	for _, key := range []string{proof.SignatureKey, proof.PhotoKey} {
+		if key == "" {
+			continue
+		}
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			correlation.Logf(ctx, "⚠️ Gagal menghapus lampiran bukti pengiriman %s: %v", key, err)
+		}
+	}
+}
+
+// newDeliveryProof memvalidasi p dan membuat baris bukti pengiriman tanpa
+// lampiran; DeliveredAt kosong diisi now.
+func newDeliveryProof(shipmentID uint, p *ProofOfDelivery, now time.Time) (*repository.DeliveryProof, error) {
+	proof := &repository.DeliveryProof{
+		ShipmentID:    shipmentID,
+		RecipientName: strings.TrimSpace(p.RecipientName),
+		Lat:           p.Lat,
+		Long:          p.Long,
+		DeliveredAt:   p.DeliveredAt,
+	}
+
+	if proof.RecipientName == "" {
+		return nil, fmt.Errorf("%w: nama penerima tidak boleh kosong", ErrInvalidProof)
+	}
+	if err := (geo.Point{Lat: p.Lat, Long: p.Long}).Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if proof.DeliveredAt.IsZero() {
+		proof.DeliveredAt = now
+	}
+	if proof.DeliveredAt.After(now.Add(maxClockSkew)) {
+		return nil, fmt.Errorf("%w: delivered_at %s ada di masa depan", ErrInvalidProof, proof.DeliveredAt.Format(time.RFC3339))
+	}
+
+	for kind, a := range map[string]*Attachment{AttachmentSignature: p.Signature, AttachmentPhoto: p.Photo} {
+		if a == nil {
+			continue
+		}
+		if len(a.Data) == 0 {
+			return nil, fmt.Errorf("%w: %s kosong", ErrInvalidProof, kind)
+		}
+		if len(a.Data) > MaxAttachmentBytes {
+			return nil, fmt.Errorf("%w: %s lebih dari %d MB", ErrInvalidProof, kind, MaxAttachmentBytes>>20)
+		}
+		if _, ok := attachmentExtensions[a.ContentType]; !ok {
+			return nil, fmt.Errorf("%w: %s harus PNG atau JPEG, bukan %s", ErrInvalidProof, kind, a.ContentType)
+		}
+	}
+	return proof, nil
+}
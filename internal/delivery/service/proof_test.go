@@ -0,0 +1,43 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidateProof(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	png := &Attachment{Data: []byte("\x89PNG..."), ContentType: "image/png"}
+
+	tests := []struct {
+		name    string
+		proof   ProofOfDelivery
+		wantErr bool
+	}{
+		{"lengkap", ProofOfDelivery{RecipientName: "Budi", Signature: png, Photo: png, Lat: -6.2, Long: 106.8, DeliveredAt: now.Add(-time.Minute)}, false},
+		{"tanpa lampiran", ProofOfDelivery{RecipientName: "Budi", Lat: -6.2, Long: 106.8}, false},
+		{"tanpa nama penerima", ProofOfDelivery{RecipientName: "  ", Lat: -6.2, Long: 106.8}, true},
+		{"koordinat tidak valid", ProofOfDelivery{RecipientName: "Budi", Lat: -91, Long: 106.8}, true},
+		{"waktu di masa depan", ProofOfDelivery{RecipientName: "Budi", Lat: -6.2, Long: 106.8, DeliveredAt: now.Add(time.Hour)}, true},
+		{"lampiran kosong", ProofOfDelivery{RecipientName: "Budi", Lat: -6.2, Long: 106.8, Photo: &Attachment{ContentType: "image/png"}}, true},
+		{"lampiran bukan gambar", ProofOfDelivery{RecipientName: "Budi", Lat: -6.2, Long: 106.8, Signature: &Attachment{Data: []byte("%PDF"), ContentType: "application/pdf"}}, true},
+		{"lampiran terlalu besar", ProofOfDelivery{RecipientName: "Budi", Lat: -6.2, Long: 106.8, Photo: &Attachment{Data: make([]byte, MaxAttachmentBytes+1), ContentType: "image/jpeg"}}, true},
+	}
+
+	for _, tt := range tests {
+		record, err := newDeliveryProof(1, &tt.proof, now)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidProof) {
+				t.Errorf("%s: error = %v, want ErrInvalidProof", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+		if record.DeliveredAt.IsZero() || record.RecipientName != "Budi" {
+			t.Errorf("%s: record = %+v", tt.name, record)
+		}
+	}
+}
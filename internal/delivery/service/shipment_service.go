@@ -1,55 +1,165 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/assignment"
+	"github.com/purnama/Event-Driven-Logistic/internal/delivery/eta"
 	"github.com/purnama/Event-Driven-Logistic/internal/delivery/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/blobstore"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+	"gorm.io/gorm"
 )
 
 type ShipmentService interface {
-	CreateShipment(orderID uuid.UUID, courierName string) (*repository.Shipment, error)
+	// CreateShipment membuat shipment untuk order dan langsung menugaskan kurir
+	// terbaik menurut strategi assignment. Tanpa kurir yang tersedia, shipment
+	// dibuat dengan status PENDING_ASSIGNMENT.
+	CreateShipment(ctx context.Context, orderID uuid.UUID) (*repository.Shipment, error)
 
 	GetShipmentByOrderID(orderID string) (*repository.Shipment, error)
 
-	UpdateShipmentStatus(shipmentID uint, status repository.ShipmentStatus) error
+	// UpdateShipmentStatus mengubah status shipment secara manual. proof wajib
+	// untuk DELIVERED dan tidak boleh diisi untuk status lain.
+	UpdateShipmentStatus(ctx context.Context, shipmentID uint, status repository.ShipmentStatus, proof *ProofOfDelivery) error
 
-	UpdateLocation(shipmentID uint, lat, long float64) error
+	GetDeliveryProof(shipmentID uint) (*repository.DeliveryProof, error)
+
+	// OpenProofAttachment membuka lampiran bukti pengiriman (AttachmentSignature
+	// atau AttachmentPhoto) beserta content type-nya.
+	OpenProofAttachment(ctx context.Context, shipmentID uint, kind string) (io.ReadCloser, string, error)
+
+	// UpdateLocation mencatat satu fix GPS kurir yang direkam sekarang.
+	UpdateLocation(ctx context.Context, shipmentID uint, lat, long float64) error
+
+	// RecordLocations mencatat satu atau lebih fix GPS kurir (misal buffer
+	// offline) ke shipment_locations dan mengembalikan jumlah fix yang baru.
+	RecordLocations(ctx context.Context, shipmentID uint, fixes []LocationFix) (int, error)
+
+	// RecordDestination menyimpan tujuan pengiriman order dari order.created.
+	// Jika shipment order sudah ada, tujuan langsung disalin dan ETA dihitung.
+	RecordDestination(ctx context.Context, orderID uuid.UUID, address string, point geo.Point) error
+
+	// AssignPendingShipments menugaskan kurir ke shipment PENDING_ASSIGNMENT
+	// terlama selama masih ada kurir yang tersedia. Mengembalikan jumlah
+	// shipment yang berhasil ditugaskan.
+	AssignPendingShipments(ctx context.Context) (int, error)
+}
+
+const pendingAssignmentBatchSize = 50
+
+var (
+	ErrShipmentNotFound = errors.New("shipment tidak ditemukan")
+
+	ErrNoCourierAssigned = errors.New("shipment belum memiliki kurir")
+)
+
+// Config berisi pengaturan shipment service.
+type Config struct {
+	// Depot adalah titik penjemputan paket, dipakai strategi nearest.
+	Depot geo.Point
+	// LocationEventInterval adalah jeda minimum antar shipment.location_updated
+	// untuk satu shipment.
+	LocationEventInterval time.Duration
+	// ETA menghitung perkiraan waktu tiba dari jarak dan kecepatan kurir.
+	ETA eta.Estimator
+	// ETAChangeThreshold adalah pergeseran ETA minimum untuk mengirim
+	// shipment.eta_changed lagi.
+	ETAChangeThreshold time.Duration
+	// PickupGeofenceKm dan DestinationGeofenceKm adalah radius depot dan tujuan
+	// untuk transisi status otomatis dari lokasi kurir.
+	PickupGeofenceKm      float64
+	DestinationGeofenceKm float64
 }
 
 type shipmentService struct {
-	repo repository.ShipmentRepository
+	repo     repository.ShipmentRepository
+	strategy assignment.Strategy
+	// blobs menyimpan lampiran bukti pengiriman.
+	blobs blobstore.Store
+	cfg   Config
 }
 
-func NewShipmentService(repo repository.ShipmentRepository) ShipmentService {
-	return &shipmentService{repo: repo}
+func NewShipmentService(repo repository.ShipmentRepository, strategy assignment.Strategy, blobs blobstore.Store, cfg Config) ShipmentService {
+	return &shipmentService{repo: repo, strategy: strategy, blobs: blobs, cfg: cfg}
 }
 
-func (s *shipmentService) CreateShipment(orderID uuid.UUID, courierName string) (*repository.Shipment, error) {
+func (s *shipmentService) CreateShipment(ctx context.Context, orderID uuid.UUID) (*repository.Shipment, error) {
 
-	if courierName == "" {
-		return nil, errors.New("nama kurir tidak boleh kosong")
+	// payment.success yang terkirim ulang tidak boleh membuat shipment kedua
+	if existing, err := s.repo.GetShipmentByOrderID(orderID.String()); err == nil {
+		correlation.Logf(ctx, "⏭️ Shipment untuk order %s sudah ada: ID=%d, Status=%s",
+			orderID, existing.ID, existing.Status)
+		return existing, nil
 	}
 
 	// Bangun model Shipment
 	shipment := &repository.Shipment{
 		OrderID:     orderID,
-		CourierName: courierName,
-		Status:      repository.ShipmentStatusPickingUp,
+		Status:      repository.ShipmentStatusPendingAssignment,
 		CurrentLat:  0,
 		CurrentLong: 0, // Koordinat awal (belum ada tracking)
 	}
 
-	// Simpan ke database
-	if err := s.repo.CreateShipment(shipment); err != nil {
-		log.Printf("❌ Gagal membuat shipment: OrderID=%s, error=%v", orderID, err)
+	// Pilih kurir dan simpan ke database bersama event shipment.created di outbox
+	err := s.repo.Transaction(func(repo repository.ShipmentRepository, couriers repository.CourierRepository, outbox *broker.Outbox) error {
+		courier, err := s.pickCourier(couriers)
+		if err != nil {
+			return err
+		}
+		if courier != nil {
+			shipment.CourierID = &courier.ID
+			shipment.CourierName = courier.Name
+			shipment.Status = repository.ShipmentStatusPickingUp
+		}
+
+		// Tujuan dikirim lewat order.created sebelum order dibayar
+		destination, err := repo.GetDestination(orderID.String())
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if destination != nil {
+			shipment.DestinationAddress = destination.Address
+			shipment.DestinationLat = &destination.Lat
+			shipment.DestinationLong = &destination.Long
+		}
+
+		if err := repo.CreateShipment(shipment); err != nil {
+			return err
+		}
+		err = outbox.EnqueueEventWithContext(ctx, broker.ShipmentCreated, broker.ShipmentCreatedPayload{
+			ShipmentID:  shipment.ID,
+			OrderID:     orderID.String(),
+			CourierID:   shipment.CourierID,
+			CourierName: shipment.CourierName,
+			Status:      string(shipment.Status),
+		})
+		if err != nil {
+			return err
+		}
+		return s.refreshETA(ctx, repo, outbox, shipment, time.Now())
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal membuat shipment: OrderID=%s, error=%v", orderID, err)
 		return nil, err
 	}
 
-	log.Printf("✅ Shipment dibuat: ID=%d, OrderID=%s, Kurir=%s, Status=PICKING_UP",
-		shipment.ID, orderID, courierName)
+	if shipment.CourierID == nil {
+		correlation.Logf(ctx, "⏳ Shipment dibuat tanpa kurir: ID=%d, OrderID=%s, Status=PENDING_ASSIGNMENT",
+			shipment.ID, orderID)
+		return shipment, nil
+	}
+
+	correlation.Logf(ctx, "✅ Shipment dibuat: ID=%d, OrderID=%s, Kurir=%s (ID=%d, strategi=%s), Status=PICKING_UP",
+		shipment.ID, orderID, shipment.CourierName, *shipment.CourierID, s.strategy.Name())
 
 	return shipment, nil
 }
@@ -68,57 +178,193 @@ func (s *shipmentService) GetShipmentByOrderID(orderID string) (*repository.Ship
 	return shipment, nil
 }
 
-func (s *shipmentService) UpdateShipmentStatus(shipmentID uint, status repository.ShipmentStatus) error {
+func (s *shipmentService) UpdateShipmentStatus(ctx context.Context, shipmentID uint, status repository.ShipmentStatus, proof *ProofOfDelivery) error {
 
 	if !status.IsValid() {
 		return errors.New("status shipment tidak valid: " + string(status))
 	}
 
-	shipment, err := s.repo.GetShipmentByID(shipmentID)
-	if err != nil {
-		return fmt.Errorf("shipment tidak ditemukan: ID=%d, error: %w", shipmentID, err)
+	// Lampiran diunggah sebelum transaksi agar baris shipment tidak terkunci
+	// selama upload; jika transaksi gagal, lampiran dihapus lagi
+	var record *repository.DeliveryProof
+	switch {
+	case status == repository.ShipmentStatusDelivered && proof == nil:
+		return fmt.Errorf("%w: ID=%d", ErrProofRequired, shipmentID)
+	case status == repository.ShipmentStatusDelivered:
+		var err error
+		if record, err = s.storeProof(ctx, shipmentID, proof); err != nil {
+			correlation.Logf(ctx, "❌ Bukti pengiriman ditolak: ShipmentID=%d, error=%v", shipmentID, err)
+			return err
+		}
+	case proof != nil:
+		return fmt.Errorf("%w: bukti hanya untuk status DELIVERED", ErrInvalidProof)
 	}
 
-	if !isValidStatusTransition(shipment.Status, status) {
-		return fmt.Errorf("transisi status tidak valid: %s → %s", shipment.Status, status)
-	}
+	var from repository.ShipmentStatus
+	err := s.repo.Transaction(func(repo repository.ShipmentRepository, _ repository.CourierRepository, outbox *broker.Outbox) error {
+		shipment, err := repo.GetShipmentByIDForUpdate(shipmentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: ID=%d", ErrShipmentNotFound, shipmentID)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Kurir hanya ditugaskan lewat AssignPendingShipments
+		if shipment.CourierID == nil {
+			return fmt.Errorf("%w: ID=%d", ErrNoCourierAssigned, shipmentID)
+		}
+
+		if !isValidStatusTransition(shipment.Status, status) {
+			return fmt.Errorf("transisi status tidak valid: %s → %s", shipment.Status, status)
+		}
+
+		if err := repo.UpdateStatus(shipmentID, status); err != nil {
+			return err
+		}
+
+		var proofID *uint
+		if record != nil {
+			if err := repo.CreateDeliveryProof(record); err != nil {
+				return err
+			}
+			proofID = &record.ID
+		}
 
-	if err := s.repo.UpdateStatus(shipmentID, status); err != nil {
-		log.Printf("❌ Gagal update status shipment: ID=%d, error=%v", shipmentID, err)
+		from = shipment.Status
+		err = outbox.EnqueueEventWithContext(ctx, broker.ShipmentStatusUpdated, broker.ShipmentStatusUpdatedPayload{
+			ShipmentID: shipmentID,
+			OrderID:    shipment.OrderID.String(),
+			CourierID:  shipment.CourierID,
+			FromStatus: string(from),
+			ToStatus:   string(status),
+			ProofID:    proofID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Rute berubah (depot sudah dilewati) atau ETA tidak berlaku lagi (DELIVERED)
+		shipment.Status = status
+		return s.refreshETA(ctx, repo, outbox, shipment, time.Now())
+	})
+	if err != nil {
+		if record != nil {
+			s.deleteProofBlobs(ctx, record)
+		}
+		correlation.Logf(ctx, "❌ Gagal update status shipment: ID=%d, error=%v", shipmentID, err)
 		return err
 	}
 
-	log.Printf("✅ Status shipment diperbarui: ID=%d, %s → %s",
-		shipmentID, shipment.Status, status)
+	correlation.Logf(ctx, "✅ Status shipment diperbarui: ID=%d, %s → %s",
+		shipmentID, from, status)
+	if record != nil {
+		correlation.Logf(ctx, "🧾 Bukti pengiriman disimpan: ProofID=%d, ShipmentID=%d, Penerima=%s, Tanda tangan=%v, Foto=%v",
+			record.ID, shipmentID, record.RecipientName, record.SignatureKey != "", record.PhotoKey != "")
+	}
+
+	// Kapasitas kurir bertambah lagi; tugaskan shipment yang masih menunggu
+	if status == repository.ShipmentStatusDelivered {
+		if _, err := s.AssignPendingShipments(ctx); err != nil {
+			correlation.Logf(ctx, "⚠️ Gagal menugaskan shipment yang menunggu kurir: %v", err)
+		}
+	}
 
 	return nil
 }
 
-func (s *shipmentService) UpdateLocation(shipmentID uint, lat, long float64) error {
+func (s *shipmentService) AssignPendingShipments(ctx context.Context) (int, error) {
+	var assigned []repository.Shipment
+	err := s.repo.Transaction(func(repo repository.ShipmentRepository, couriers repository.CourierRepository, outbox *broker.Outbox) error {
+		pending, err := repo.LockPendingAssignment(pendingAssignmentBatchSize)
+		if err != nil {
+			return err
+		}
 
-	if lat < -90 || lat > 90 {
-		return fmt.Errorf("latitude tidak valid: %f (harus antara -90 dan 90)", lat)
+		for _, shipment := range pending {
+			courier, err := s.pickCourier(couriers)
+			if err != nil {
+				return err
+			}
+			if courier == nil {
+				return nil
+			}
+			if !isValidStatusTransition(shipment.Status, repository.ShipmentStatusPickingUp) {
+				continue
+			}
+
+			if err := repo.AssignCourier(shipment.ID, courier); err != nil {
+				return err
+			}
+			err = outbox.EnqueueEventWithContext(ctx, broker.ShipmentStatusUpdated, broker.ShipmentStatusUpdatedPayload{
+				ShipmentID: shipment.ID,
+				OrderID:    shipment.OrderID.String(),
+				CourierID:  &courier.ID,
+				FromStatus: string(shipment.Status),
+				ToStatus:   string(repository.ShipmentStatusPickingUp),
+			})
+			if err != nil {
+				return err
+			}
+
+			shipment.CourierID = &courier.ID
+			shipment.CourierName = courier.Name
+			shipment.Status = repository.ShipmentStatusPickingUp
+			if err := s.refreshETA(ctx, repo, outbox, &shipment, time.Now()); err != nil {
+				return err
+			}
+			assigned = append(assigned, shipment)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	if long < -180 || long > 180 {
-		return fmt.Errorf("longitude tidak valid: %f (harus antara -180 dan 180)", long)
+	for _, shipment := range assigned {
+		correlation.Logf(ctx, "✅ Kurir ditugaskan: ShipmentID=%d, OrderID=%s, Kurir=%s (ID=%d)",
+			shipment.ID, shipment.OrderID, shipment.CourierName, *shipment.CourierID)
 	}
+	return len(assigned), nil
+}
 
-	if err := s.repo.UpdateLocation(shipmentID, lat, long); err != nil {
-		log.Printf("❌ Gagal update lokasi shipment: ID=%d, error=%v", shipmentID, err)
-		return err
+// pickCourier memilih kurir dengan strategi assignment lalu mengunci barisnya.
+// Kurir yang ternyata sudah penuh (diambil transaksi lain) dilewati dan
+// strategi memilih lagi dari sisa kandidat. Mengembalikan nil jika tidak
+// ada kurir yang tersedia.
+func (s *shipmentService) pickCourier(couriers repository.CourierRepository) (*repository.Courier, error) {
+	candidates, err := couriers.ListAssignable()
+	if err != nil {
+		return nil, err
 	}
 
-	log.Printf("📍 Lokasi kurir diperbarui: ShipmentID=%d, Lat=%f, Long=%f",
-		shipmentID, lat, long)
+	for len(candidates) > 0 {
+		chosen, _ := s.strategy.Pick(candidates, s.cfg.Depot)
 
-	return nil
+		load, err := couriers.LockCourierLoad(chosen.ID)
+		if err != nil {
+			return nil, err
+		}
+		if load.Available && load.ActiveShipments < load.Capacity {
+			return &load.Courier, nil
+		}
+
+		for i := range candidates {
+			if candidates[i].ID == chosen.ID {
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil, nil
 }
 
 func isValidStatusTransition(from, to repository.ShipmentStatus) bool {
 	validTransitions := map[repository.ShipmentStatus][]repository.ShipmentStatus{
-		repository.ShipmentStatusPickingUp: {repository.ShipmentStatusOnTheWay},
-		repository.ShipmentStatusOnTheWay:  {repository.ShipmentStatusDelivered},
+		repository.ShipmentStatusPendingAssignment: {repository.ShipmentStatusPickingUp},
+		repository.ShipmentStatusPickingUp:         {repository.ShipmentStatusOnTheWay},
+		repository.ShipmentStatusOnTheWay:          {repository.ShipmentStatusArriving, repository.ShipmentStatusDelivered},
+		repository.ShipmentStatusArriving:          {repository.ShipmentStatusDelivered},
 	}
 
 	allowed, exists := validTransitions[from]
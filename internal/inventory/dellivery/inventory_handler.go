@@ -8,6 +8,7 @@ import (
 
 	"github.com/gin-gonic/gin"                                            
 	"github.com/purnama/Event-Driven-Logistic/internal/inventory/service" 
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
 	"github.com/purnama/Event-Driven-Logistic/pkg/response"               
 )
 
@@ -71,11 +72,31 @@ func (h *InventoryHandler) GetProductByID(c *gin.Context) {
 	response.Success(c, "Produk ditemukan", product) 
 }
 
+// GetPrices mengembalikan harga katalog untuk produk yang disebut di query ?name=A&name=B.
+// Dipakai order service untuk menghitung total order.
+func (h *InventoryHandler) GetPrices(c *gin.Context) {
+	names := c.QueryArray("name")
+	if len(names) == 0 {
+		response.Error(c, http.StatusBadRequest, "Parameter name wajib diisi")
+		return
+	}
+
+	products, err := h.svc.GetProductsByNames(names)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Gagal mengambil harga produk")
+		return
+	}
+
+	response.Success(c, "Harga produk berhasil diambil", products)
+}
+
 func (h *InventoryHandler) CreateProduct(c *gin.Context) {
 	
 	var req struct {
-		Name  string `json:"name" binding:"required"`  
-		Stock int    `json:"stock" binding:"required"` 
+		Name     string  `json:"name" binding:"required"`  
+		Stock    int     `json:"stock" binding:"required"` 
+		Price    money.Money `json:"price" binding:"required"`
+		Currency string      `json:"currency"`
 	}
 
 	
@@ -85,7 +106,11 @@ func (h *InventoryHandler) CreateProduct(c *gin.Context) {
 	}
 
 	
-	product, err := h.svc.CreateProduct(req.Name, req.Stock) 
+	if req.Price.Currency == "" {
+		req.Price.Currency = req.Currency
+	}
+
+	product, err := h.svc.CreateProduct(req.Name, req.Stock, req.Price) 
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "Gagal membuat produk: "+err.Error()) 
 		return                                                                                  
@@ -6,6 +6,7 @@ func RegisterRoutes(router *gin.Engine, handler *InventoryHandler) {
 	products := router.Group("/products")
 	{
 		products.GET("", handler.ListProducts)
+		products.GET("/prices", handler.GetPrices)
 		products.GET("/:id", handler.GetProductByID)
 		products.POST("", handler.CreateProduct)
 	}
@@ -1,95 +1,191 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 
 	"github.com/google/uuid"
 	"github.com/purnama/Event-Driven-Logistic/internal/inventory/service"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
 )
 
 type InventoryConsumer struct {
-	consumer  *broker.Consumer
-	publisher *broker.Publisher
-	svc       service.InventoryService
+	consumer    *broker.Consumer
+	svc         service.InventoryService
+	reserveOpts broker.SubscribeOptions
+}
+
+// DefaultReserveOptions dipakai untuk queue order.created, jalur tersibuk saat peak.
+// Reservasi diproses paralel, tetap berurutan per order.
+func DefaultReserveOptions() broker.SubscribeOptions {
+	opts := broker.DefaultSubscribeOptions()
+	opts.Workers = 8
+	opts.Prefetch = 32
+	return opts
 }
 
 func NewInventoryConsumer(
 	consumer *broker.Consumer,
-	publisher *broker.Publisher,
 	svc service.InventoryService,
+	reserveOpts broker.SubscribeOptions,
 ) *InventoryConsumer {
 	return &InventoryConsumer{
-		consumer:  consumer,
-		publisher: publisher,
-		svc:       svc,
+		consumer:    consumer,
+		svc:         svc,
+		reserveOpts: reserveOpts,
 	}
 }
 
 func (ic *InventoryConsumer) StartListening() error {
 
-	if err := ic.consumer.Subscribe(
+	if err := ic.consumer.SubscribeHandler(
 		"inventory.order.created",
 		"order.created",
 		ic.handleOrderCreated,
+		ic.reserveOpts,
+	); err != nil {
+		return err
+	}
+
+	if err := ic.consumer.SubscribeHandler(
+		"inventory.payment.success",
+		"payment.success",
+		ic.handlePaymentSuccess,
+		broker.DefaultSubscribeOptions(),
+	); err != nil {
+		return err
+	}
+
+	if err := ic.consumer.SubscribeHandler(
+		"inventory.payment.failed",
+		"payment.failed",
+		ic.handlePaymentFailed,
+		broker.DefaultSubscribeOptions(),
+	); err != nil {
+		return err
+	}
+
+	if err := ic.consumer.SubscribeHandler(
+		"inventory.order.cancelled",
+		"order.cancelled",
+		ic.handleOrderCancelled,
+		broker.DefaultSubscribeOptions(),
 	); err != nil {
 		return err
 	}
 
-	log.Println("✅ Inventory Consumer: listening for order.created")
+	log.Println("✅ Inventory Consumer: listening for order.created, payment.success, payment.failed, order.cancelled")
 	return nil
 }
 
-func (ic *InventoryConsumer) handleOrderCreated(event broker.Event) error {
+func (ic *InventoryConsumer) handleOrderCreated(ctx context.Context, d *broker.Delivery) error {
 
 	var payload broker.OrderCreatedPayload
-	if err := json.Unmarshal(event.Payload, &payload); err != nil {
-		log.Printf("❌ Gagal parse OrderCreatedPayload: %v", err)
-		return err
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse OrderCreatedPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	log.Printf("📨 Order.created diterima di Inventory: OrderID=%s, Item=%s, Qty=%d",
-		payload.OrderID, payload.ItemName, payload.Quantity)
+	correlation.Logf(ctx, "📨 Order.created diterima di Inventory: OrderID=%s, Items=%d",
+		payload.OrderID, len(payload.Items))
 
 	orderID, err := uuid.Parse(payload.OrderID)
 	if err != nil {
-		log.Printf("❌ Format OrderID tidak valid: %s", payload.OrderID)
-		return err
+		correlation.Logf(ctx, "❌ Format OrderID tidak valid: %s", payload.OrderID)
+		return broker.Permanent(err)
 	}
 
-	reservation, err := ic.svc.ReserveStock(orderID, payload.ItemName, payload.Quantity)
+	items := make([]service.ReserveItem, 0, len(payload.Items))
+	for _, item := range payload.Items {
+		items = append(items, service.ReserveItem{ProductName: item.ItemName, Quantity: item.Quantity})
+	}
+
+	// stock.reserved/stock.failed ditulis ke outbox oleh ReserveStock. Error
+	// selain kekurangan stok dikembalikan agar event masuk retry queue.
+	reservations, err := ic.svc.ReserveStock(ctx, orderID, items)
+	var shortage *service.InsufficientStockError
+	if errors.As(err, &shortage) {
+		correlation.Logf(ctx, "⚠️ Stok gagal direservasi, stock.failed dikirim: %v", err)
+		return nil
+	}
+	if errors.Is(err, service.ErrOrderCancelled) {
+		correlation.Logf(ctx, "⏭️ Order sudah dibatalkan sebelum reservasi, stok tidak dikurangi: OrderID=%s", payload.OrderID)
+		return nil
+	}
 	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal reservasi stok: OrderID=%s, error=%v", payload.OrderID, err)
+		return err
+	}
+
+	correlation.Logf(ctx, "✅ Stok berhasil direservasi: OrderID=%s, Lines=%d",
+		payload.OrderID, len(reservations))
 
-		log.Printf("⚠️ Stok gagal direservasi: %v", err)
+	return nil
+}
 
-		failPayload := broker.StockFailedPayload{
-			OrderID:  payload.OrderID,
-			ItemName: payload.ItemName,
-			Reason:   err.Error(),
-		}
+func (ic *InventoryConsumer) handlePaymentSuccess(ctx context.Context, d *broker.Delivery) error {
 
-		if pubErr := ic.publisher.PublishEvent(broker.StockFailed, failPayload); pubErr != nil {
-			log.Printf("❌ Gagal publish stock.failed: %v", pubErr)
-		}
+	var payload broker.PaymentSuccessPayload
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse PaymentSuccessPayload: %v", err)
+		return broker.Permanent(err)
+	}
 
+	correlation.Logf(ctx, "📨 Payment.success diterima di Inventory: OrderID=%s", payload.OrderID)
+
+	// Reservasi yang belum ada dicoba lagi, karena order.created diproses di
+	// queue lain dan bisa tertinggal di belakang payment.success
+	err := ic.svc.ConfirmReservation(ctx, payload.OrderID)
+	if errors.Is(err, service.ErrReservationReleased) || errors.Is(err, service.ErrOrderCancelled) {
+		correlation.Logf(ctx, "⚠️ Reservasi tidak dapat dikonfirmasi, event diabaikan: %v", err)
 		return nil
 	}
+	if errors.Is(err, service.ErrReservationNotFound) {
+		correlation.Logf(ctx, "⏳ Reservasi belum ada, payment.success dicoba lagi: OrderID=%s", payload.OrderID)
+	}
+	return err
+}
 
-	successPayload := broker.StockReservedPayload{
-		OrderID:       payload.OrderID,
-		ProductID:     reservation.ProductID,
-		Quantity:      reservation.Quantity,
-		ReservationID: reservation.ID,
+func (ic *InventoryConsumer) handlePaymentFailed(ctx context.Context, d *broker.Delivery) error {
+
+	var payload broker.PaymentFailedPayload
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse PaymentFailedPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	if err := ic.publisher.PublishEvent(broker.StockReserved, successPayload); err != nil {
-		log.Printf("❌ Gagal publish stock.reserved: %v", err)
-		return err
+	correlation.Logf(ctx, "📨 Payment.failed diterima di Inventory: OrderID=%s, Reason=%s",
+		payload.OrderID, payload.Reason)
+
+	return ic.releaseReservation(ctx, payload.OrderID)
+}
+
+func (ic *InventoryConsumer) handleOrderCancelled(ctx context.Context, d *broker.Delivery) error {
+
+	var payload broker.OrderCancelledPayload
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse OrderCancelledPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	log.Printf("✅ Stok berhasil direservasi dan event dipublish: OrderID=%s, Qty=%d",
-		payload.OrderID, payload.Quantity)
+	correlation.Logf(ctx, "📨 Order.cancelled diterima di Inventory: OrderID=%s", payload.OrderID)
 
-	return nil
+	return ic.releaseReservation(ctx, payload.OrderID)
+}
+
+func (ic *InventoryConsumer) releaseReservation(ctx context.Context, orderID string) error {
+	if _, err := uuid.Parse(orderID); err != nil {
+		correlation.Logf(ctx, "❌ Format OrderID tidak valid: %s", orderID)
+		return broker.Permanent(err)
+	}
+
+	err := ic.svc.ReleaseReservation(ctx, orderID)
+	if errors.Is(err, service.ErrReservationNotFound) {
+		correlation.Logf(ctx, "ℹ️ Belum ada stok yang direservasi, order ditandai dibatalkan: OrderID=%s", orderID)
+		return nil
+	}
+	return err
 }
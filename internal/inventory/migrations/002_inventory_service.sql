@@ -7,6 +7,7 @@ CREATE TABLE IF NOT EXISTS products (
     name VARCHAR(255) NOT NULL,
     stock INTEGER NOT NULL DEFAULT 0,
     price DECIMAL(12, 2) NOT NULL DEFAULT 0.00,
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
@@ -27,7 +28,7 @@ CREATE TABLE IF NOT EXISTS stock_reservations (
 
 -- Create indexes
 CREATE INDEX idx_products_name ON products(name);
-CREATE INDEX idx_stock_reservations_order_id ON stock_reservations(order_id);
+CREATE UNIQUE INDEX idx_stock_reservations_order_product ON stock_reservations(order_id, product_id);
 CREATE INDEX idx_stock_reservations_product_id ON stock_reservations(product_id);
 CREATE INDEX idx_stock_reservations_status ON stock_reservations(status);
 
@@ -58,3 +59,37 @@ INSERT INTO products (name, stock, price) VALUES
     ('Es Teh Manis', 200, 5000.00),
     ('Jus Alpukat', 60, 15000.00)
 ON CONFLICT DO NOTHING;
+
+-- Create cancelled_orders table (order yang dilepas sebelum stoknya direservasi)
+CREATE TABLE IF NOT EXISTS cancelled_orders (
+    order_id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create outbox table (transactional outbox untuk event stock.*)
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id SERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    body TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE,
+    failed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Dipakai relay untuk mencari pesan yang belum terkirim; pesan rusak (failed_at) tidak ikut
+CREATE INDEX idx_outbox_messages_next_attempt_at ON outbox_messages(next_attempt_at) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_messages_published_at ON outbox_messages(published_at);
+
+-- Create processed_events table (event yang sudah diproses per queue, untuk idempotency consumer)
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(64) NOT NULL,
+    consumer VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, consumer)
+);
+
+CREATE INDEX idx_processed_events_processed_at ON processed_events(processed_at);
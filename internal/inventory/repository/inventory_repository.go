@@ -1,23 +1,47 @@
 package repository
 
 import (
+	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProductRepository interface {
 	CreateProduct(product *Product) error
 	GetProductByID(id uint) (*Product, error)
 	GetProductByName(name string) (*Product, error)
+	GetProductsByNames(names []string) ([]Product, error)
 	UpdateProduct(product *Product) error
 	UpdateStock(productID uint, newStock int) error
+	IncrementStock(productID uint, delta int) error
+	// DecrementStock mengurangi stok hanya jika stok masih mencukupi.
+	// Return false jika stok kurang dari quantity; stok tidak diubah.
+	DecrementStock(productID uint, quantity int) (bool, error)
 	ListProducts(limit, offset int) ([]Product, error)
+
+	// Transaction menjalankan fn dalam satu transaksi DB; perubahan stok,
+	// reservasi, dan event yang ditulis ke outbox ter-commit bersama atau
+	// di-rollback bersama.
+	Transaction(fn func(productRepo ProductRepository, reservationRepo StockReservationRepository, outbox *broker.Outbox) error) error
 }
 
 type StockReservationRepository interface {
 	CreateReservation(reservation *StockReservation) error
-	GetReservationByOrderID(orderID string) (*StockReservation, error)
+	// GetReservationsByOrderID mengambil semua reservasi (satu per produk) milik order.
+	GetReservationsByOrderID(orderID string) ([]StockReservation, error)
 	UpdateReservationStatus(reservationID uint, status ProductStatus) error
+	// TransitionReservationStatus hanya mengubah status jika status saat ini ada di from.
+	// Return false jika reservasi sudah berpindah status (misal event terkirim ulang).
+	TransitionReservationStatus(reservationID uint, from []ProductStatus, to ProductStatus) (bool, error)
 	DeleteReservation(reservationID uint) error
+
+	// LockOrder mengunci order sampai transaksi selesai (advisory lock), sehingga
+	// reservasi dan pelepasan stok untuk order yang sama tidak berjalan bersamaan.
+	LockOrder(orderID string) error
+	// MarkOrderCancelled mencatat order yang dibatalkan; marker yang sudah ada dibiarkan.
+	MarkOrderCancelled(orderID uuid.UUID) error
+	IsOrderCancelled(orderID string) (bool, error)
 }
 type productRepository struct {
 	db *gorm.DB
@@ -55,12 +79,39 @@ func (r *productRepository) GetProductByName(name string) (*Product, error) {
 	}
 	return &product, nil
 }
+func (r *productRepository) GetProductsByNames(names []string) ([]Product, error) {
+	var products []Product
+	err := r.db.Where("name IN ?", names).Find(&products).Error
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
 func (r *productRepository) UpdateProduct(product *Product) error {
 	return r.db.Save(product).Error
 }
 func (r *productRepository) UpdateStock(productID uint, newStock int) error {
 	return r.db.Model(&Product{}).Where("id = ?", productID).Update("stock", newStock).Error
 }
+func (r *productRepository) IncrementStock(productID uint, delta int) error {
+	return r.db.Model(&Product{}).Where("id = ?", productID).
+		Update("stock", gorm.Expr("stock + ?", delta)).Error
+}
+func (r *productRepository) DecrementStock(productID uint, quantity int) (bool, error) {
+	// Kondisi stock >= ? dievaluasi ulang setelah row lock didapat,
+	// sehingga dua transaksi paralel tidak bisa menjual stok yang sama.
+	result := r.db.Model(&Product{}).Where("id = ? AND stock >= ?", productID, quantity).
+		Update("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+func (r *productRepository) Transaction(fn func(productRepo ProductRepository, reservationRepo StockReservationRepository, outbox *broker.Outbox) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&productRepository{db: tx}, &stockReservationRepository{db: tx}, broker.NewOutbox(tx))
+	})
+}
 func (r *productRepository) ListProducts(limit, offset int) ([]Product, error) {
 	var products []Product
 	err := r.db.Limit(limit).Offset(offset).Find(&products).Error
@@ -72,17 +123,38 @@ func (r *productRepository) ListProducts(limit, offset int) ([]Product, error) {
 func (r *stockReservationRepository) CreateReservation(reservation *StockReservation) error {
 	return r.db.Create(reservation).Error
 }
-func (r *stockReservationRepository) GetReservationByOrderID(orderID string) (*StockReservation, error) {
-	var reservation StockReservation
-	err := r.db.Where("order_id = ?", orderID).First(&reservation).Error
+func (r *stockReservationRepository) GetReservationsByOrderID(orderID string) ([]StockReservation, error) {
+	var reservations []StockReservation
+	err := r.db.Where("order_id = ?", orderID).Order("product_id").Find(&reservations).Error
 	if err != nil {
 		return nil, err
 	}
-	return &reservation, nil
+	return reservations, nil
 }
 func (r *stockReservationRepository) UpdateReservationStatus(reservationID uint, status ProductStatus) error {
-	return r.db.Model(&StockReservation{}).Where("id = ?", reservationID).Update("status", status).Error
+	return r.db.Model(&StockReservation{Status: status}).Where("id = ?", reservationID).Update("status", status).Error
+}
+func (r *stockReservationRepository) TransitionReservationStatus(reservationID uint, from []ProductStatus, to ProductStatus) (bool, error) {
+	// Status diisi di model agar hook BeforeUpdate memvalidasi status tujuan
+	result := r.db.Model(&StockReservation{Status: to}).
+		Where("id = ? AND status IN ?", reservationID, from).
+		Update("status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
 }
 func (r *stockReservationRepository) DeleteReservation(reservationID uint) error {
 	return r.db.Delete(&StockReservation{}, reservationID).Error
 }
+func (r *stockReservationRepository) LockOrder(orderID string) error {
+	return r.db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", orderID).Error
+}
+func (r *stockReservationRepository) MarkOrderCancelled(orderID uuid.UUID) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&CancelledOrder{OrderID: orderID}).Error
+}
+func (r *stockReservationRepository) IsOrderCancelled(orderID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&CancelledOrder{}).Where("order_id = ?", orderID).Count(&count).Error
+	return count > 0, err
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
 	"gorm.io/gorm"
 )
 
@@ -25,26 +26,49 @@ func (s ProductStatus) IsValid() bool {
 }
 
 type Product struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"not null;uniqueIndex" json:"name"`
-	Stock     int       `gorm:"default:0;check:stock >= 0" json:"stock"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	Name      string      `gorm:"not null;uniqueIndex" json:"name"`
+	Stock     int         `gorm:"default:0;check:stock >= 0" json:"stock"`
+	Price     money.Money `gorm:"type:decimal(12,2);not null;default:0;check:price >= 0" json:"price"`
+	Currency  string      `gorm:"type:varchar(3);not null;default:IDR" json:"currency"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
 }
 type StockReservation struct {
 	ID        uint          `gorm:"primaryKey" json:"id"`
-	OrderID   uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
-	ProductID uint          `gorm:"not null;index" json:"product_id"`
+	OrderID   uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_stock_reservations_order_product" json:"order_id"`
+	ProductID uint          `gorm:"not null;index;uniqueIndex:idx_stock_reservations_order_product" json:"product_id"`
 	Quantity  int           `gorm:"not null;check:quantity > 0" json:"quantity"`
 	Status    ProductStatus `gorm:"type:varchar(20);default:RESERVED;not null" json:"status"`
 	CreatedAt time.Time     `json:"created_at"`
 	UpdatedAt time.Time     `json:"updated_at"`
 }
 
+// CancelledOrder menandai order yang reservasinya sudah diminta dilepas
+// (payment.failed atau order.cancelled). order.created yang diproses
+// belakangan tidak lagi mengurangi stok untuk order ini.
+type CancelledOrder struct {
+	OrderID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"order_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 func (p *Product) BeforeCreate(tx *gorm.DB) (err error) {
 	if p.Stock < 0 {
 		return fmt.Errorf("product stock cannot be negative: %d", p.Stock)
 	}
+	if p.Price.IsNegative() {
+		return fmt.Errorf("product price cannot be negative: %s", p.Price)
+	}
+	if p.Currency == "" {
+		p.Currency = p.Price.WithDefaultCurrency().Currency
+	}
+
+	return nil
+}
+
+// AfterFind mengisi currency Price dari kolom currency.
+func (p *Product) AfterFind(tx *gorm.DB) (err error) {
+	p.Price.Currency = p.Currency
 
 	return nil
 }
@@ -1,19 +1,73 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/purnama/Event-Driven-Logistic/internal/inventory/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+	"gorm.io/gorm"
 )
 
+// ErrReservationNotFound dikembalikan jika order tidak memiliki reservasi stok,
+// misalnya karena reservasi sebelumnya gagal (stock.failed).
+var ErrReservationNotFound = errors.New("reservasi stok tidak ditemukan")
+
+// ErrReservationReleased dikembalikan saat konfirmasi reservasi yang sudah dilepas.
+var ErrReservationReleased = errors.New("reservasi stok sudah dilepas")
+
+// ErrOrderCancelled dikembalikan jika reservasi order sudah diminta dilepas
+// (payment.failed atau order.cancelled) sebelum stoknya direservasi.
+var ErrOrderCancelled = errors.New("order sudah dibatalkan")
+
+// ErrInsufficientStock dikembalikan jika stok produk tidak mencukupi quantity yang diminta.
+var ErrInsufficientStock = errors.New("stok tidak cukup")
+
+// ReserveItem adalah satu baris order yang akan direservasi.
+type ReserveItem struct {
+	ProductName string
+	Quantity    int
+}
+
+// ShortItem adalah baris order yang tidak dapat dipenuhi.
+type ShortItem struct {
+	ProductName string
+	Requested   int
+	Available   int
+	Reason      string
+}
+
+// InsufficientStockError berisi semua baris yang gagal direservasi.
+// errors.Is(err, ErrInsufficientStock) bernilai true.
+type InsufficientStockError struct {
+	Items []ShortItem
+}
+
+func (e *InsufficientStockError) Error() string {
+	lines := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		lines = append(lines, fmt.Sprintf("'%s' (%s: diminta=%d, tersedia=%d)",
+			item.ProductName, item.Reason, item.Requested, item.Available))
+	}
+	return fmt.Sprintf("%v: %s", ErrInsufficientStock, strings.Join(lines, ", "))
+}
+
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
 // InventoryService mendefinisikan kontrak bisnis logic untuk Inventory.
 // Semua method mengembalikan error jika operasi gagal.
 type InventoryService interface {
 	// CreateProduct menambahkan produk baru ke inventory.
-	CreateProduct(name string, stock int) (*repository.Product, error)
+	CreateProduct(name string, stock int, price money.Money) (*repository.Product, error)
 
 	// ListProducts mengambil daftar produk dengan pagination.
 	ListProducts(limit, offset int) ([]repository.Product, error)
@@ -21,14 +75,26 @@ type InventoryService interface {
 	// GetProductByID mengambil produk berdasarkan ID.
 	GetProductByID(id uint) (*repository.Product, error)
 
-	// ReserveStock mereservasi stok untuk sebuah order.
-	ReserveStock(orderID uuid.UUID, productName string, quantity int) (*repository.StockReservation, error)
-
-	// ConfirmReservation mengkonfirmasi reservasi stok.
-	ConfirmReservation(orderID string) error
-
-	// ReleaseReservation mengembalikan stok yang direservasi.
-	ReleaseReservation(orderID string) error
+	// GetProductsByNames mengambil produk (termasuk harga katalog) berdasarkan nama.
+	// Nama yang tidak ditemukan tidak ikut dikembalikan.
+	GetProductsByNames(names []string) ([]repository.Product, error)
+
+	// ReserveStock mereservasi stok semua baris order sekaligus: semua berhasil
+	// atau tidak ada yang direservasi (*InsufficientStockError). stock.reserved
+	// atau stock.failed ditulis ke outbox; error lain tidak menghasilkan event.
+	// Order yang sudah dilepas lewat ReleaseReservation mengembalikan ErrOrderCancelled.
+	ReserveStock(ctx context.Context, orderID uuid.UUID, items []ReserveItem) ([]repository.StockReservation, error)
+
+	// ConfirmReservation mengkonfirmasi semua reservasi stok milik order.
+	// ErrReservationNotFound berarti reservasi belum dibuat dan bisa dicoba lagi;
+	// ErrOrderCancelled berarti order sudah dilepas sebelum direservasi.
+	ConfirmReservation(ctx context.Context, orderID string) error
+
+	// ReleaseReservation mengembalikan stok semua reservasi milik order dan
+	// menandai order dibatalkan, sehingga reservasi yang datang belakangan
+	// tidak mengurangi stok. Jika belum ada reservasi, marker tetap disimpan
+	// dan ErrReservationNotFound dikembalikan.
+	ReleaseReservation(ctx context.Context, orderID string) error
 }
 
 type inventoryService struct {
@@ -47,9 +113,10 @@ func NewInventoryService(
 }
 
 // CreateProduct menambahkan produk baru ke inventory.
-// Parameter: name — nama produk (wajib), stock — jumlah stok awal (>= 0).
+// Parameter: name — nama produk (wajib), stock — jumlah stok awal (>= 0),
+// price — harga katalog per unit (> 0, currency default IDR).
 // Return: product yang berhasil dibuat, atau error jika validasi gagal.
-func (s *inventoryService) CreateProduct(name string, stock int) (*repository.Product, error) {
+func (s *inventoryService) CreateProduct(name string, stock int, price money.Money) (*repository.Product, error) {
 	// Validasi nama produk — tidak boleh kosong
 	if name == "" {
 		return nil, errors.New("nama produk tidak boleh kosong") // Guard clause
@@ -60,10 +127,23 @@ func (s *inventoryService) CreateProduct(name string, stock int) (*repository.Pr
 		return nil, errors.New("stok awal tidak boleh negatif") // Guard clause
 	}
 
+	// Validasi harga — order menghitung total dari harga katalog
+	if !price.IsPositive() {
+		return nil, errors.New("harga produk harus lebih dari 0") // Guard clause
+	}
+
+	// Currency default IDR, selalu disimpan huruf besar
+	price = money.New(price.Amount, price.WithDefaultCurrency().Currency)
+	if len(price.Currency) != 3 {
+		return nil, fmt.Errorf("currency tidak valid: %s", price.Currency) // Guard clause
+	}
+
 	// Buat entity produk baru
 	product := &repository.Product{
-		Name:  name,  // Nama produk dari parameter
-		Stock: stock, // Stok awal dari parameter
+		Name:     name,           // Nama produk dari parameter
+		Stock:    stock,          // Stok awal dari parameter
+		Price:    price,          // Harga katalog per unit
+		Currency: price.Currency, // Mata uang harga
 	}
 
 	// Simpan ke database via repository
@@ -72,8 +152,8 @@ func (s *inventoryService) CreateProduct(name string, stock int) (*repository.Pr
 		return nil, fmt.Errorf("gagal membuat produk: %w", err)            // Wrap error
 	}
 
-	log.Printf("✅ Produk berhasil dibuat: ID=%d, Name=%s, Stock=%d", product.ID, name, stock) // Log sukses
-	return product, nil                                                                       // Return produk baru
+	log.Printf("✅ Produk berhasil dibuat: ID=%d, Name=%s, Stock=%d, Price=%s", product.ID, name, stock, price) // Log sukses
+	return product, nil                                                                                        // Return produk baru
 }
 
 func (s *inventoryService) ListProducts(limit, offset int) ([]repository.Product, error) {
@@ -105,83 +185,297 @@ func (s *inventoryService) GetProductByID(id uint) (*repository.Product, error)
 	return product, nil
 }
 
-func (s *inventoryService) ReserveStock(orderID uuid.UUID, productName string, quantity int) (*repository.StockReservation, error) {
-
-	if quantity <= 0 {
-		return nil, errors.New("quantity harus lebih dari 0")
+func (s *inventoryService) GetProductsByNames(names []string) ([]repository.Product, error) {
+	if len(names) == 0 {
+		return nil, errors.New("nama produk tidak boleh kosong")
 	}
 
-	product, err := s.productRepo.GetProductByName(productName)
+	products, err := s.productRepo.GetProductsByNames(names)
 	if err != nil {
-		return nil, fmt.Errorf("produk '%s' tidak ditemukan: %w", productName, err)
+		log.Printf("❌ Gagal mengambil produk berdasarkan nama: error=%v", err)
+		return nil, err
 	}
+	return products, nil
+}
 
-	if product.Stock < quantity {
-		return nil, fmt.Errorf("stok tidak cukup untuk '%s': tersedia=%d, diminta=%d",
-			productName, product.Stock, quantity)
-	}
+func (s *inventoryService) ReserveStock(ctx context.Context, orderID uuid.UUID, items []ReserveItem) ([]repository.StockReservation, error) {
 
-	newStock := product.Stock - quantity
-	if err := s.productRepo.UpdateStock(product.ID, newStock); err != nil {
-		return nil, fmt.Errorf("gagal mengurangi stok: %w", err)
+	if len(items) == 0 {
+		return nil, errors.New("order tidak memiliki item")
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity '%s' harus lebih dari 0", item.ProductName)
+		}
 	}
 
-	reservation := &repository.StockReservation{
-		OrderID:   orderID,
-		ProductID: product.ID,
-		Quantity:  quantity,
-		Status:    repository.ProductStatusReserved,
+	var reservations []repository.StockReservation
+	existing := false
+
+	// Pengurangan stok dan pembuatan reservasi semua baris berada dalam satu
+	// transaksi; jika satu baris gagal, stok baris lain kembali lewat rollback.
+	err := s.productRepo.Transaction(func(productRepo repository.ProductRepository, reservationRepo repository.StockReservationRepository, outbox *broker.Outbox) error {
+
+		// Lock order yang sama dengan ReleaseReservation: pembatalan yang sedang
+		// berjalan selesai dulu, lalu marker-nya terlihat di sini
+		if err := reservationRepo.LockOrder(orderID.String()); err != nil {
+			return fmt.Errorf("gagal mengunci order: %s, error: %w", orderID, err)
+		}
+		cancelled, err := reservationRepo.IsOrderCancelled(orderID.String())
+		if err != nil {
+			return fmt.Errorf("gagal memeriksa pembatalan order: %s, error: %w", orderID, err)
+		}
+		if cancelled {
+			return fmt.Errorf("%w: OrderID=%s", ErrOrderCancelled, orderID)
+		}
+
+		found, err := reservationRepo.GetReservationsByOrderID(orderID.String())
+		if err != nil {
+			return fmt.Errorf("gagal mengambil reservasi untuk order: %s, error: %w", orderID, err)
+		}
+		if len(found) > 0 {
+			reservations, existing = found, true
+			return nil
+		}
+
+		type line struct {
+			item    ReserveItem
+			product *repository.Product
+		}
+		lines := make([]line, 0, len(items))
+		var short []ShortItem
+
+		for _, item := range items {
+			product, err := productRepo.GetProductByName(item.ProductName)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				short = append(short, ShortItem{
+					ProductName: item.ProductName,
+					Requested:   item.Quantity,
+					Reason:      "produk tidak ditemukan",
+				})
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("gagal mengambil produk '%s': %w", item.ProductName, err)
+			}
+			lines = append(lines, line{item: item, product: product})
+		}
+
+		// Kunci baris produk selalu dengan urutan ID yang sama agar dua order
+		// dengan produk yang sama tidak saling deadlock.
+		sort.Slice(lines, func(i, j int) bool { return lines[i].product.ID < lines[j].product.ID })
+
+		for _, l := range lines {
+			reserved, err := productRepo.DecrementStock(l.product.ID, l.item.Quantity)
+			if err != nil {
+				return fmt.Errorf("gagal mengurangi stok '%s': %w", l.item.ProductName, err)
+			}
+			if reserved {
+				continue
+			}
+
+			current, err := productRepo.GetProductByID(l.product.ID)
+			if err != nil {
+				return fmt.Errorf("gagal mengambil stok '%s': %w", l.item.ProductName, err)
+			}
+			short = append(short, ShortItem{
+				ProductName: l.item.ProductName,
+				Requested:   l.item.Quantity,
+				Available:   current.Stock,
+				Reason:      "stok tidak cukup",
+			})
+		}
+
+		if len(short) > 0 {
+			return &InsufficientStockError{Items: short}
+		}
+
+		for _, l := range lines {
+			reservation := repository.StockReservation{
+				OrderID:   orderID,
+				ProductID: l.product.ID,
+				Quantity:  l.item.Quantity,
+				Status:    repository.ProductStatusReserved,
+			}
+			if err := reservationRepo.CreateReservation(&reservation); err != nil {
+				return fmt.Errorf("gagal membuat reservasi stok '%s': %w", l.item.ProductName, err)
+			}
+			reservations = append(reservations, reservation)
+		}
+
+		return outbox.EnqueueEventWithContext(ctx, broker.StockReserved, stockReservedPayload(orderID, reservations))
+	})
+
+	var shortage *InsufficientStockError
+	if errors.As(err, &shortage) {
+		// Stok sudah di-rollback, jadi stock.failed ditulis di transaksi sendiri.
+		// Jika gagal, error yang dikembalikan bukan shortage sehingga event dicoba lagi.
+		outErr := s.productRepo.Transaction(func(_ repository.ProductRepository, _ repository.StockReservationRepository, outbox *broker.Outbox) error {
+			return outbox.EnqueueEventWithContext(ctx, broker.StockFailed, stockFailedPayload(orderID, shortage))
+		})
+		if outErr != nil {
+			return nil, fmt.Errorf("gagal menulis stock.failed untuk order: %s, error: %w", orderID, outErr)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.reservationRepo.CreateReservation(reservation); err != nil {
+	if existing {
+		correlation.Logf(ctx, "ℹ️ Reservasi sudah ada untuk order: OrderID=%s, Lines=%d", orderID, len(reservations))
+		return reservations, nil
+	}
 
-		_ = s.productRepo.UpdateStock(product.ID, product.Stock)
-		return nil, fmt.Errorf("gagal membuat reservasi stok: %w", err)
+	for _, reservation := range reservations {
+		correlation.Logf(ctx, "✅ Stok direservasi: OrderID=%s, ProductID=%d, Qty=%d, ReservationID=%d",
+			orderID, reservation.ProductID, reservation.Quantity, reservation.ID)
 	}
 
-	log.Printf("✅ Stok direservasi: OrderID=%s, Product=%s, Qty=%d, Sisa=%d",
-		orderID, productName, quantity, newStock)
+	return reservations, nil
+}
 
-	return reservation, nil
+func stockReservedPayload(orderID uuid.UUID, reservations []repository.StockReservation) broker.StockReservedPayload {
+	payload := broker.StockReservedPayload{OrderID: orderID.String()}
+	for _, reservation := range reservations {
+		payload.Items = append(payload.Items, broker.ReservedItemPayload{
+			ProductID:     reservation.ProductID,
+			Quantity:      reservation.Quantity,
+			ReservationID: reservation.ID,
+		})
+	}
+	return payload
 }
 
-func (s *inventoryService) ConfirmReservation(orderID string) error {
+func stockFailedPayload(orderID uuid.UUID, shortage *InsufficientStockError) broker.StockFailedPayload {
+	payload := broker.StockFailedPayload{OrderID: orderID.String(), Reason: shortage.Error()}
+	for _, item := range shortage.Items {
+		payload.Items = append(payload.Items, broker.FailedItemPayload{
+			ItemName:  item.ProductName,
+			Requested: item.Requested,
+			Available: item.Available,
+			Reason:    item.Reason,
+		})
+	}
+	return payload
+}
 
-	reservation, err := s.reservationRepo.GetReservationByOrderID(orderID)
+func (s *inventoryService) ConfirmReservation(ctx context.Context, orderID string) error {
+
+	reservations, err := s.getReservations(orderID)
+	if errors.Is(err, ErrReservationNotFound) {
+		cancelled, cErr := s.reservationRepo.IsOrderCancelled(orderID)
+		if cErr != nil {
+			return fmt.Errorf("gagal memeriksa pembatalan order: %s, error: %w", orderID, cErr)
+		}
+		if cancelled {
+			return fmt.Errorf("%w: OrderID=%s", ErrOrderCancelled, orderID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	confirmed := 0
+	err = s.productRepo.Transaction(func(productRepo repository.ProductRepository, reservationRepo repository.StockReservationRepository, outbox *broker.Outbox) error {
+		for _, reservation := range reservations {
+			ok, err := reservationRepo.TransitionReservationStatus(reservation.ID,
+				[]repository.ProductStatus{repository.ProductStatusReserved}, repository.ProductStatusConfirmed)
+			if err != nil {
+				return fmt.Errorf("gagal konfirmasi reservasi: %w", err)
+			}
+			if !ok && reservation.Status == repository.ProductStatusReleased {
+				return fmt.Errorf("%w: OrderID=%s, ReservationID=%d", ErrReservationReleased, orderID, reservation.ID)
+			}
+			if ok {
+				confirmed++
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("reservasi tidak ditemukan untuk order: %s, error: %w", orderID, err)
+		return err
 	}
 
-	if err := s.reservationRepo.UpdateReservationStatus(reservation.ID, repository.ProductStatusConfirmed); err != nil {
-		return fmt.Errorf("gagal konfirmasi reservasi: %w", err)
+	if confirmed == 0 {
+		correlation.Logf(ctx, "ℹ️ Reservasi sudah dikonfirmasi sebelumnya: OrderID=%s", orderID)
+		return nil
 	}
 
-	log.Printf("✅ Reservasi dikonfirmasi: OrderID=%s, ReservationID=%d", orderID, reservation.ID)
+	correlation.Logf(ctx, "✅ Reservasi dikonfirmasi: OrderID=%s, Lines=%d", orderID, confirmed)
 	return nil
 }
-func (s *inventoryService) ReleaseReservation(orderID string) error {
+func (s *inventoryService) ReleaseReservation(ctx context.Context, orderID string) error {
 
-	reservation, err := s.reservationRepo.GetReservationByOrderID(orderID)
+	id, err := uuid.Parse(orderID)
 	if err != nil {
-		return fmt.Errorf("reservasi tidak ditemukan untuk order: %s, error: %w", orderID, err)
+		return fmt.Errorf("order_id tidak valid: %s", orderID)
 	}
 
-	product, err := s.productRepo.GetProductByID(reservation.ProductID)
+	// Klaim reservasi dulu; hanya pemanggil yang berhasil mengubah status
+	// yang boleh mengembalikan stok, sehingga event ganda tidak dihitung dua kali.
+	// Marker pembatalan, klaim dan pengembalian stok berada dalam satu
+	// transaksi di bawah lock order yang juga diambil ReserveStock.
+	var reservations, released []repository.StockReservation
+	err = s.productRepo.Transaction(func(productRepo repository.ProductRepository, reservationRepo repository.StockReservationRepository, outbox *broker.Outbox) error {
+		if err := reservationRepo.LockOrder(orderID); err != nil {
+			return fmt.Errorf("gagal mengunci order: %s, error: %w", orderID, err)
+		}
+		if err := reservationRepo.MarkOrderCancelled(id); err != nil {
+			return fmt.Errorf("gagal menandai order dibatalkan: %s, error: %w", orderID, err)
+		}
+
+		var err error
+		reservations, err = reservationRepo.GetReservationsByOrderID(orderID)
+		if err != nil {
+			return fmt.Errorf("gagal mengambil reservasi untuk order: %s, error: %w", orderID, err)
+		}
+
+		for _, reservation := range reservations {
+			ok, err := reservationRepo.TransitionReservationStatus(reservation.ID,
+				[]repository.ProductStatus{repository.ProductStatusReserved, repository.ProductStatusConfirmed},
+				repository.ProductStatusReleased)
+			if err != nil {
+				return fmt.Errorf("gagal update status reservasi: %w", err)
+			}
+			if !ok {
+				continue
+			}
+
+			if err := productRepo.IncrementStock(reservation.ProductID, reservation.Quantity); err != nil {
+				return fmt.Errorf("gagal mengembalikan stok: %w", err)
+			}
+			released = append(released, reservation)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("produk tidak ditemukan: ID=%d, error: %w", reservation.ProductID, err)
+		return err
 	}
 
-	newStock := product.Stock + reservation.Quantity
-	if err := s.productRepo.UpdateStock(product.ID, newStock); err != nil {
-		return fmt.Errorf("gagal mengembalikan stok: %w", err)
+	if len(reservations) == 0 {
+		return fmt.Errorf("%w: OrderID=%s", ErrReservationNotFound, orderID)
 	}
-
-	if err := s.reservationRepo.UpdateReservationStatus(reservation.ID, repository.ProductStatusReleased); err != nil {
-		return fmt.Errorf("gagal update status reservasi: %w", err)
+	if len(released) == 0 {
+		correlation.Logf(ctx, "ℹ️ Reservasi sudah dilepas sebelumnya: OrderID=%s", orderID)
+		return nil
 	}
 
-	log.Printf("✅ Stok dikembalikan: OrderID=%s, Product=%s, Qty=%d, StokBaru=%d",
-		orderID, product.Name, reservation.Quantity, newStock)
+	for _, reservation := range released {
+		correlation.Logf(ctx, "✅ Stok dikembalikan: OrderID=%s, ProductID=%d, Qty=%d",
+			orderID, reservation.ProductID, reservation.Quantity)
+	}
 
 	return nil
 }
+
+func (s *inventoryService) getReservations(orderID string) ([]repository.StockReservation, error) {
+	reservations, err := s.reservationRepo.GetReservationsByOrderID(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil reservasi untuk order: %s, error: %w", orderID, err)
+	}
+	if len(reservations) == 0 {
+		return nil, fmt.Errorf("%w: OrderID=%s", ErrReservationNotFound, orderID)
+	}
+	return reservations, nil
+}
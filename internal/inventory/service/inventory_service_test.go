@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/internal/inventory/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB membuka koneksi ke Postgres dari TEST_DB_URL; test dilewati jika tidak diset.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DB_URL")
+	if dsn == "" {
+		t.Skip("TEST_DB_URL tidak diset, test database dilewati")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gagal koneksi ke database: %v", err)
+	}
+	if err := db.AutoMigrate(&repository.Product{}, &repository.StockReservation{}, &repository.CancelledOrder{}, &broker.OutboxMessage{}); err != nil {
+		t.Fatalf("gagal migrasi: %v", err)
+	}
+	return db
+}
+
+func newTestProduct(t *testing.T, db *gorm.DB, svc InventoryService, stock int) *repository.Product {
+	t.Helper()
+
+	product, err := svc.CreateProduct("test-"+uuid.NewString(), stock, money.New(1000000, "IDR"))
+	if err != nil {
+		t.Fatalf("gagal membuat produk: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("product_id = ?", product.ID).Delete(&repository.StockReservation{})
+		db.Delete(&repository.Product{}, product.ID)
+	})
+	return product
+}
+
+func TestReserveStockConcurrentNoOversell(t *testing.T) {
+	db := openTestDB(t)
+	svc := NewInventoryService(repository.NewProductRepository(db), repository.NewStockReservationRepository(db))
+
+	const stock, orders = 10, 50
+	product := newTestProduct(t, db, svc, stock)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved, insufficient := 0, 0
+	for i := 0; i < orders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.ReserveStock(context.Background(), uuid.New(), []ReserveItem{{ProductName: product.Name, Quantity: 1}})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, ErrInsufficientStock):
+				insufficient++
+			default:
+				t.Errorf("error tidak terduga: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != stock {
+		t.Errorf("reserved = %d, want %d", reserved, stock)
+	}
+	if insufficient != orders-stock {
+		t.Errorf("insufficient = %d, want %d", insufficient, orders-stock)
+	}
+
+	got, err := svc.GetProductByID(product.ID)
+	if err != nil {
+		t.Fatalf("gagal mengambil produk: %v", err)
+	}
+	if got.Stock != 0 {
+		t.Errorf("stock = %d, want 0", got.Stock)
+	}
+}
+
+func TestReleaseReservationConcurrentOnce(t *testing.T) {
+	db := openTestDB(t)
+	svc := NewInventoryService(repository.NewProductRepository(db), repository.NewStockReservationRepository(db))
+
+	product := newTestProduct(t, db, svc, 5)
+	orderID := uuid.New()
+	if _, err := svc.ReserveStock(context.Background(), orderID, []ReserveItem{{ProductName: product.Name, Quantity: 3}}); err != nil {
+		t.Fatalf("gagal reservasi: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.ReleaseReservation(context.Background(), orderID.String()); err != nil {
+				t.Errorf("gagal release: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := svc.GetProductByID(product.ID)
+	if err != nil {
+		t.Fatalf("gagal mengambil produk: %v", err)
+	}
+	if got.Stock != 5 {
+		t.Errorf("stock = %d, want 5", got.Stock)
+	}
+}
+
+func TestReserveStockSameOrderIsIdempotent(t *testing.T) {
+	db := openTestDB(t)
+	svc := NewInventoryService(repository.NewProductRepository(db), repository.NewStockReservationRepository(db))
+
+	product := newTestProduct(t, db, svc, 5)
+	orderID := uuid.New()
+	items := []ReserveItem{{ProductName: product.Name, Quantity: 2}}
+	first, err := svc.ReserveStock(context.Background(), orderID, items)
+	if err != nil {
+		t.Fatalf("gagal reservasi: %v", err)
+	}
+	second, err := svc.ReserveStock(context.Background(), orderID, items)
+	if err != nil {
+		t.Fatalf("gagal reservasi ulang: %v", err)
+	}
+	if len(second) != 1 || first[0].ID != second[0].ID {
+		t.Errorf("reservations = %+v, want %+v", second, first)
+	}
+
+	got, err := svc.GetProductByID(product.ID)
+	if err != nil {
+		t.Fatalf("gagal mengambil produk: %v", err)
+	}
+	if got.Stock != 3 {
+		t.Errorf("stock = %d, want 3", got.Stock)
+	}
+}
+
+func TestReserveStockMultiLineAllOrNothing(t *testing.T) {
+	db := openTestDB(t)
+	svc := NewInventoryService(repository.NewProductRepository(db), repository.NewStockReservationRepository(db))
+
+	plenty := newTestProduct(t, db, svc, 10)
+	scarce := newTestProduct(t, db, svc, 1)
+	orderID := uuid.New()
+
+	_, err := svc.ReserveStock(context.Background(), orderID, []ReserveItem{
+		{ProductName: plenty.Name, Quantity: 4},
+		{ProductName: scarce.Name, Quantity: 2},
+		{ProductName: "tidak-ada-" + uuid.NewString(), Quantity: 1},
+	})
+
+	var shortage *InsufficientStockError
+	if !errors.As(err, &shortage) {
+		t.Fatalf("err = %v, want *InsufficientStockError", err)
+	}
+	if len(shortage.Items) != 2 {
+		t.Fatalf("short items = %+v, want 2 baris", shortage.Items)
+	}
+	for _, item := range shortage.Items {
+		if item.ProductName == scarce.Name && item.Available != 1 {
+			t.Errorf("available %s = %d, want 1", item.ProductName, item.Available)
+		}
+	}
+
+	got, err := svc.GetProductByID(plenty.ID)
+	if err != nil {
+		t.Fatalf("gagal mengambil produk: %v", err)
+	}
+	if got.Stock != 10 {
+		t.Errorf("stock = %d, want 10 (rollback)", got.Stock)
+	}
+	if err := svc.ConfirmReservation(context.Background(), orderID.String()); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("confirm err = %v, want ErrReservationNotFound", err)
+	}
+
+	reservations, err := svc.ReserveStock(context.Background(), orderID, []ReserveItem{
+		{ProductName: plenty.Name, Quantity: 4},
+		{ProductName: scarce.Name, Quantity: 1},
+	})
+	if err != nil {
+		t.Fatalf("gagal reservasi: %v", err)
+	}
+	if len(reservations) != 2 {
+		t.Errorf("reservations = %d, want 2", len(reservations))
+	}
+}
+
+func TestReleaseBeforeReserveSkipsOrder(t *testing.T) {
+	db := openTestDB(t)
+	svc := NewInventoryService(repository.NewProductRepository(db), repository.NewStockReservationRepository(db))
+
+	product := newTestProduct(t, db, svc, 5)
+	orderID := uuid.New()
+	t.Cleanup(func() { db.Delete(&repository.CancelledOrder{}, "order_id = ?", orderID) })
+
+	// order.cancelled diproses sebelum order.created
+	if err := svc.ReleaseReservation(context.Background(), orderID.String()); !errors.Is(err, ErrReservationNotFound) {
+		t.Fatalf("release err = %v, want ErrReservationNotFound", err)
+	}
+
+	_, err := svc.ReserveStock(context.Background(), orderID, []ReserveItem{{ProductName: product.Name, Quantity: 2}})
+	if !errors.Is(err, ErrOrderCancelled) {
+		t.Fatalf("reserve err = %v, want ErrOrderCancelled", err)
+	}
+	if err := svc.ConfirmReservation(context.Background(), orderID.String()); !errors.Is(err, ErrOrderCancelled) {
+		t.Errorf("confirm err = %v, want ErrOrderCancelled", err)
+	}
+
+	got, err := svc.GetProductByID(product.ID)
+	if err != nil {
+		t.Fatalf("gagal mengambil produk: %v", err)
+	}
+	if got.Stock != 5 {
+		t.Errorf("stock = %d, want 5", got.Stock)
+	}
+}
+
+func TestInsufficientStockErrorIs(t *testing.T) {
+	err := error(&InsufficientStockError{Items: []ShortItem{
+		{ProductName: "Nasi Goreng", Requested: 3, Available: 1, Reason: "stok tidak cukup"},
+	}})
+
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Errorf("errors.Is(err, ErrInsufficientStock) = false")
+	}
+	want := "stok tidak cukup: 'Nasi Goreng' (stok tidak cukup: diminta=3, tersedia=1)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
@@ -1,11 +1,13 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 
 	"github.com/purnama/Event-Driven-Logistic/internal/notification/service"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
 )
 
 type NotificationConsumer struct {
@@ -28,18 +30,25 @@ type eventBinding struct {
 func (nc *NotificationConsumer) StartListening() error {
 	bindings := []eventBinding{
 		{Queue: "notif.order.created", RoutingKey: "order.created"},
+		{Queue: "notif.order.cancelled", RoutingKey: "order.cancelled"},
 		{Queue: "notif.payment.success", RoutingKey: "payment.success"},
 		{Queue: "notif.payment.failed", RoutingKey: "payment.failed"},
+		{Queue: "notif.payment.refunded", RoutingKey: "payment.refunded"},
 		{Queue: "notif.stock.reserved", RoutingKey: "stock.reserved"},
 		{Queue: "notif.stock.failed", RoutingKey: "stock.failed"},
+		{Queue: "notif.shipment.created", RoutingKey: "shipment.created"},
+		{Queue: "notif.shipment.status_updated", RoutingKey: "shipment.status_updated"},
+		{Queue: "notif.shipment.location_updated", RoutingKey: "shipment.location_updated"},
+		{Queue: "notif.shipment.eta_changed", RoutingKey: "shipment.eta_changed"},
 	}
 
 	for _, b := range bindings {
 
-		err := nc.consumer.Subscribe(
+		err := nc.consumer.SubscribeHandler(
 			b.Queue,
 			b.RoutingKey,
 			nc.handleEvent,
+			broker.DefaultSubscribeOptions(),
 		)
 		if err != nil {
 			return err
@@ -49,11 +58,11 @@ func (nc *NotificationConsumer) StartListening() error {
 	log.Println("✅ Notification Consumer: listening for ALL events")
 	return nil
 }
-func (nc *NotificationConsumer) handleEvent(event broker.Event) error {
+func (nc *NotificationConsumer) handleEvent(ctx context.Context, d *broker.Delivery) error {
 
 	var payloadMap map[string]interface{}
-	if err := json.Unmarshal(event.Payload, &payloadMap); err != nil {
-		log.Printf("⚠️ Gagal parse payload, using empty: %v", err)
+	if err := json.Unmarshal(d.Event.Payload, &payloadMap); err != nil {
+		correlation.Logf(ctx, "⚠️ Gagal parse payload, using empty: %v", err)
 		payloadMap = map[string]interface{}{}
 	}
 
@@ -62,8 +71,9 @@ func (nc *NotificationConsumer) handleEvent(event broker.Event) error {
 		orderID, _ = oid.(string)
 	}
 	return nc.svc.ProcessEvent(
-		string(event.Type),
+		ctx,
+		string(d.Event.Type),
 		orderID,
-		string(event.Payload),
+		string(d.Event.Payload),
 	)
 }
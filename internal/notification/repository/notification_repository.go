@@ -9,6 +9,7 @@ type NotificationRepository interface {
 	SaveLog(log *NotificationLog) error                         
 	GetLogsByOrderID(orderID string) ([]NotificationLog, error) 
 	GetRecentLogs(limit int) ([]NotificationLog, error)         
+	GetLogsByCorrelationID(correlationID string) ([]NotificationLog, error)
 }
 
 
@@ -37,3 +38,9 @@ func (r *notificationRepository) GetRecentLogs(limit int) ([]NotificationLog, er
 	result := r.db.Order("created_at DESC").Limit(limit).Find(&logs) 
 	return logs, result.Error                                        
 }
+
+func (r *notificationRepository) GetLogsByCorrelationID(correlationID string) ([]NotificationLog, error) {
+	var logs []NotificationLog
+	result := r.db.Where("correlation_id = ?", correlationID).Order("created_at ASC").Find(&logs)
+	return logs, result.Error
+}
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"log"
 
 	"github.com/purnama/Event-Driven-Logistic/internal/notification/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
 )
 
 type Broadcaster interface {
@@ -12,9 +14,35 @@ type Broadcaster interface {
 }
 
 type NotificationService interface {
-	ProcessEvent(eventType, orderID, payload string) error
+	ProcessEvent(ctx context.Context, eventType, orderID, payload string) error
 	GetRecentLogs(limit int) ([]repository.NotificationLog, error)
 	GetLogsByOrderID(orderID string) ([]repository.NotificationLog, error)
+	GetLogsByCorrelationID(correlationID string) ([]repository.NotificationLog, error)
+}
+
+// wsMessage adalah pesan yang dikirim ke dashboard lewat WebSocket.
+type wsMessage struct {
+	EventType     string          `json:"event_type"`
+	OrderID       string          `json:"order_id"`
+	CorrelationID string          `json:"correlation_id"`
+	Message       string          `json:"message"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// newWSMessage menyisipkan payload apa adanya jika JSON valid; selain itu
+// payload dikirim sebagai string agar pesan tetap valid.
+func newWSMessage(eventType, orderID, correlationID, message, payload string) wsMessage {
+	raw := json.RawMessage(payload)
+	if !json.Valid(raw) {
+		raw, _ = json.Marshal(payload)
+	}
+	return wsMessage{
+		EventType:     eventType,
+		OrderID:       orderID,
+		CorrelationID: correlationID,
+		Message:       message,
+		Payload:       raw,
+	}
 }
 
 type notificationService struct {
@@ -33,40 +61,52 @@ func humanMessage(eventType string) string {
 	switch eventType {
 	case "order.created":
 		return "📦 Pesanan baru dibuat"
+	case "order.cancelled":
+		return "🚫 Pesanan dibatalkan"
 	case "payment.success":
 		return "💳 Pembayaran berhasil"
 	case "payment.failed":
 		return "❌ Pembayaran gagal"
+	case "payment.refunded":
+		return "💸 Dana dikembalikan"
 	case "stock.reserved":
 		return "📦 Stok berhasil direservasi"
 	case "stock.failed":
 		return "⚠️ Stok tidak tersedia"
+	case "shipment.created":
+		return "🚚 Pengiriman dibuat"
+	case "shipment.status_updated":
+		return "📍 Status pengiriman diperbarui"
+	case "shipment.location_updated":
+		return "🛵 Lokasi kurir diperbarui"
+	case "shipment.eta_changed":
+		return "⏱️ Estimasi waktu tiba diperbarui"
 	default:
 		return fmt.Sprintf("🔔 Event: %s", eventType)
 	}
 }
 
-func (s *notificationService) ProcessEvent(eventType, orderID, payload string) error {
+func (s *notificationService) ProcessEvent(ctx context.Context, eventType, orderID, payload string) error {
 	notifLog := &repository.NotificationLog{
-		EventType: eventType,
-		OrderID:   orderID,
-		Payload:   payload,
-		Message:   humanMessage(eventType),
+		EventType:     eventType,
+		OrderID:       orderID,
+		Payload:       payload,
+		Message:       humanMessage(eventType),
+		CorrelationID: correlation.CorrelationID(ctx),
 	}
 
 	if err := s.repo.SaveLog(notifLog); err != nil {
-		log.Printf("❌ Gagal menyimpan notification log: %v", err)
+		correlation.Logf(ctx, "❌ Gagal menyimpan notification log: %v", err)
 		return err
 	}
-	log.Printf("✅ Notification log disimpan: [%s] order=%s", eventType, orderID)
+	correlation.Logf(ctx, "✅ Notification log disimpan: [%s] order=%s", eventType, orderID)
 
-	wsMessage := fmt.Sprintf(`{"event_type":"%s","order_id":"%s","message":"%s","payload":%s}`,
-		eventType,
-		orderID,
-		humanMessage(eventType),
-		payload,
-	)
-	s.hub.Broadcast([]byte(wsMessage))
+	message, err := json.Marshal(newWSMessage(eventType, orderID, notifLog.CorrelationID, notifLog.Message, payload))
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal membuat pesan WebSocket: %v", err)
+		return err
+	}
+	s.hub.Broadcast(message)
 
 	return nil
 }
@@ -77,3 +117,7 @@ func (s *notificationService) GetRecentLogs(limit int) ([]repository.Notificatio
 func (s *notificationService) GetLogsByOrderID(orderID string) ([]repository.NotificationLog, error) {
 	return s.repo.GetLogsByOrderID(orderID)
 }
+
+func (s *notificationService) GetLogsByCorrelationID(correlationID string) ([]repository.NotificationLog, error) {
+	return s.repo.GetLogsByCorrelationID(correlationID)
+}
@@ -0,0 +1,90 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+)
+
+// ErrUnavailable dikembalikan jika katalog inventory tidak dapat dihubungi
+// atau mengembalikan response yang tidak valid.
+var ErrUnavailable = errors.New("katalog produk tidak tersedia")
+
+const defaultTimeout = 5 * time.Second
+
+// ProductPrice adalah harga katalog satu produk.
+type ProductPrice struct {
+	Name     string      `json:"name"`
+	Price    money.Money `json:"price"`
+	Currency string      `json:"currency"`
+}
+
+// Catalog mengambil harga produk yang berlaku saat order dibuat.
+type Catalog interface {
+	// GetPrices mengembalikan harga per nama produk. Nama yang tidak ada di
+	// katalog tidak muncul di map.
+	GetPrices(ctx context.Context, names []string) (map[string]ProductPrice, error)
+}
+
+type inventoryClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewInventoryClient membuat Catalog yang membaca harga langsung dari
+// endpoint GET /products/prices milik inventory service.
+func NewInventoryClient(baseURL string) Catalog {
+	return &inventoryClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+func (c *inventoryClient) GetPrices(ctx context.Context, names []string) (map[string]ProductPrice, error) {
+	query := url.Values{}
+	for _, name := range names {
+		query.Add("name", name)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/products/prices?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if id := correlation.CorrelationID(ctx); id != "" {
+		req.Header.Set(correlation.Header, id)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: inventory mengembalikan status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var body struct {
+		Data []ProductPrice `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: response tidak valid: %v", ErrUnavailable, err)
+	}
+
+	prices := make(map[string]ProductPrice, len(body.Data))
+	for _, p := range body.Data {
+		if p.Price.Currency == "" {
+			p.Price.Currency = p.Currency
+		}
+		prices[p.Name] = p
+	}
+	return prices, nil
+}
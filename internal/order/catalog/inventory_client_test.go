@@ -0,0 +1,54 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+)
+
+func TestInventoryClientGetPrices(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/products/prices" {
+			t.Errorf("path = %s, want /products/prices", r.URL.Path)
+		}
+		if got := r.URL.Query()["name"]; len(got) != 2 {
+			t.Errorf("name = %v, want 2 nilai", got)
+		}
+		if got := r.Header.Get(correlation.Header); got != "cid-1" {
+			t.Errorf("%s = %q, want cid-1", correlation.Header, got)
+		}
+		w.Write([]byte(`{"status":"success","data":[{"id":1,"name":"Nasi Goreng","stock":5,"price":{"value":"25000.00","currency":"IDR"},"currency":"IDR"}]}`))
+	}))
+	defer server.Close()
+
+	ctx := correlation.WithCorrelationID(context.Background(), "cid-1")
+	prices, err := NewInventoryClient(server.URL+"/").GetPrices(ctx, []string{"Nasi Goreng", "Es Teh"})
+	if err != nil {
+		t.Fatalf("GetPrices error: %v", err)
+	}
+
+	want := ProductPrice{Name: "Nasi Goreng", Price: money.New(2500000, "IDR"), Currency: "IDR"}
+	if prices["Nasi Goreng"] != want {
+		t.Errorf("price = %+v, want %+v", prices["Nasi Goreng"], want)
+	}
+	if _, ok := prices["Es Teh"]; ok {
+		t.Errorf("produk yang tidak ada tidak boleh muncul")
+	}
+}
+
+func TestInventoryClientUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	_, err := NewInventoryClient(server.URL).GetPrices(context.Background(), []string{"Nasi Goreng"})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want ErrUnavailable", err)
+	}
+}
@@ -1,27 +1,22 @@
 package delivery
 
 import (
-	"log"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/purnama/Event-Driven-Logistic/internal/order/event"
+	"github.com/purnama/Event-Driven-Logistic/internal/order/catalog"
 	"github.com/purnama/Event-Driven-Logistic/internal/order/service"
-	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
 	"github.com/purnama/Event-Driven-Logistic/pkg/response"
 )
 
 type OrderHandler struct {
-	svc       service.OrderService
-	publisher *event.OrderPublisher
+	svc service.OrderService
 }
 
-func NewOrderHandler(svc service.OrderService, publisher *event.OrderPublisher) *OrderHandler {
-	return &OrderHandler{
-		svc:       svc,
-		publisher: publisher,
-	}
+func NewOrderHandler(svc service.OrderService) *OrderHandler {
+	return &OrderHandler{svc: svc}
 }
 
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
@@ -32,26 +27,22 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.svc.CreateOrder(req)
+	order, err := h.svc.CreateOrder(c.Request.Context(), req)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "Gagal membuat order: "+err.Error())
+		switch {
+		case errors.Is(err, service.ErrUnknownProduct), errors.Is(err, service.ErrMixedCurrency),
+			errors.Is(err, service.ErrInvalidAddress):
+			response.Error(c, http.StatusBadRequest, "Order tidak valid: "+err.Error())
+		case errors.Is(err, catalog.ErrUnavailable):
+			response.Error(c, http.StatusServiceUnavailable, "Harga produk tidak dapat diambil, coba lagi nanti")
+		default:
+			response.Error(c, http.StatusInternalServerError, "Gagal membuat order: "+err.Error())
+		}
 		return
 	}
 
-	if h.publisher != nil {
-		go func() {
-			payload := broker.OrderCreatedPayload{
-				OrderID:    order.ID.String(), // UUID → string
-				CustomerID: order.CustomerID,  // ID customer
-				ItemName:   order.ItemName,    // Nama item
-				Quantity:   order.Quantity,    // Jumlah
-				TotalPrice: order.TotalPrice,  // Total harga
-			}
-			if err := h.publisher.PublishOrderCreated(payload); err != nil {
-				log.Printf("⚠️ Failed to publish order.created event: %v", err)
-			}
-		}()
-	}
+	// Event order.created sudah tersimpan di outbox bersama order,
+	// OutboxRelay yang akan mempublishnya ke RabbitMQ.
 
 	// Kirim response sukses
 	c.JSON(http.StatusCreated, response.Response{
@@ -95,3 +86,58 @@ func (h *OrderHandler) GetOrdersByCustomerID(c *gin.Context) {
 
 	response.Success(c, "Riwayat order ditemukan", orders)
 }
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Format ID tidak valid, gunakan UUID")
+		return
+	}
+
+	var req CancelOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Request tidak valid: "+err.Error())
+		return
+	}
+
+	order, err := h.svc.CancelOrder(c.Request.Context(), id, req.Reason, service.ActorAPI)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			response.Error(c, http.StatusNotFound, "Order tidak ditemukan")
+		case errors.Is(err, service.ErrInvalidStatusTransition):
+			response.Error(c, http.StatusConflict, "Order tidak dapat dibatalkan: "+err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "Gagal membatalkan order: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "Order berhasil dibatalkan", order)
+}
+
+func (h *OrderHandler) GetStatusHistory(c *gin.Context) {
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Format ID tidak valid, gunakan UUID")
+		return
+	}
+
+	history, err := h.svc.GetStatusHistory(id)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			response.Error(c, http.StatusNotFound, "Order tidak ditemukan")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Gagal mengambil riwayat status order")
+		return
+	}
+
+	response.Success(c, "Riwayat status order ditemukan", history)
+}
@@ -7,6 +7,8 @@ func RegisterRoutes(router *gin.Engine, handler *OrderHandler) {
 	{
 		orders.POST("", handler.CreateOrder)
 		orders.GET("/:id", handler.GetOrderByID)
+		orders.POST("/:id/cancel", handler.CancelOrder)
+		orders.GET("/:id/history", handler.GetStatusHistory)
 		orders.GET("/user/:user_id", handler.GetOrdersByCustomerID)
 	}
 }
@@ -1,127 +1,267 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 
 	"github.com/google/uuid"
 	"github.com/purnama/Event-Driven-Logistic/internal/order/repository"
+	"github.com/purnama/Event-Driven-Logistic/internal/order/service"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+)
+
+// Status shipment dari delivery service yang memengaruhi status order.
+const (
+	shipmentPendingAssignment = "PENDING_ASSIGNMENT"
+	shipmentPickingUp         = "PICKING_UP"
+	shipmentDelivered         = "DELIVERED"
 )
 
 type OrderConsumer struct {
 	consumer *broker.Consumer
-	repo     repository.OrderRepository
+	svc      service.OrderService
 }
 
-func NewOrderConsumer(consumer *broker.Consumer, repo repository.OrderRepository) *OrderConsumer {
+func NewOrderConsumer(consumer *broker.Consumer, svc service.OrderService) *OrderConsumer {
 	return &OrderConsumer{
 		consumer: consumer,
-		repo:     repo,
+		svc:      svc,
 	}
 }
 
 func (oc *OrderConsumer) StartListening() error {
 
-	if err := oc.consumer.Subscribe(
+	if err := oc.consumer.SubscribeHandler(
 		"order.payment.success",
 		"payment.success",
 		oc.handlePaymentSuccess,
+		broker.DefaultSubscribeOptions(),
 	); err != nil {
 		return err
 	}
 
-	if err := oc.consumer.Subscribe(
+	if err := oc.consumer.SubscribeHandler(
 		"order.payment.failed",
 		"payment.failed",
 		oc.handlePaymentFailed,
+		broker.DefaultSubscribeOptions(),
 	); err != nil {
 		return err
 	}
 
-	if err := oc.consumer.Subscribe(
+	if err := oc.consumer.SubscribeHandler(
 		"order.stock.failed",
 		"stock.failed",
 		oc.handleStockFailed,
+		broker.DefaultSubscribeOptions(),
 	); err != nil {
 		return err
 	}
 
-	log.Println("✅ Order Consumer: listening for payment.success, payment.failed, stock.failed")
+	if err := oc.consumer.SubscribeHandler(
+		"order.payment.refunded",
+		"payment.refunded",
+		oc.handlePaymentRefunded,
+		broker.DefaultSubscribeOptions(),
+	); err != nil {
+		return err
+	}
+
+	if err := oc.consumer.SubscribeHandler(
+		"order.shipment.created",
+		"shipment.created",
+		oc.handleShipmentCreated,
+		broker.DefaultSubscribeOptions(),
+	); err != nil {
+		return err
+	}
+
+	if err := oc.consumer.SubscribeHandler(
+		"order.shipment.status_updated",
+		"shipment.status_updated",
+		oc.handleShipmentStatusUpdated,
+		broker.DefaultSubscribeOptions(),
+	); err != nil {
+		return err
+	}
+
+	log.Println("✅ Order Consumer: listening for payment.success, payment.failed, payment.refunded, stock.failed, shipment.created, shipment.status_updated")
 	return nil
 }
 
-func (oc *OrderConsumer) handlePaymentSuccess(event broker.Event) error {
+func (oc *OrderConsumer) handlePaymentSuccess(ctx context.Context, d *broker.Delivery) error {
 
 	var payload broker.PaymentSuccessPayload
-	if err := json.Unmarshal(event.Payload, &payload); err != nil {
-		log.Printf("❌ Gagal parse PaymentSuccessPayload: %v", err)
-		return err
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse PaymentSuccessPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	log.Printf("📨 Payment sukses diterima: OrderID=%s, Amount=%.2f",
+	correlation.Logf(ctx, "📨 Payment sukses diterima: OrderID=%s, Amount=%s",
 		payload.OrderID, payload.Amount)
 
 	orderID, err := uuid.Parse(payload.OrderID)
 	if err != nil {
-		log.Printf("❌ Format OrderID tidak valid: %s", payload.OrderID)
-		return err
+		correlation.Logf(ctx, "❌ Format OrderID tidak valid: %s", payload.OrderID)
+		return broker.Permanent(err)
 	}
 
-	if err := oc.repo.UpdateStatus(orderID, repository.PAID); err != nil {
-		log.Printf("❌ Gagal update order status ke PAID: %v", err)
-		return err
-	}
-
-	log.Printf("✅ Order %s status diperbarui: PENDING → PAID", payload.OrderID)
-	return nil
+	_, err = oc.svc.UpdateOrderStatus(ctx, orderID, repository.PAID, eventActor(d))
+	return handleStatusError(ctx, err)
 }
 
-func (oc *OrderConsumer) handlePaymentFailed(event broker.Event) error {
+func (oc *OrderConsumer) handlePaymentFailed(ctx context.Context, d *broker.Delivery) error {
 
 	var payload broker.PaymentFailedPayload
-	if err := json.Unmarshal(event.Payload, &payload); err != nil {
-		log.Printf("❌ Gagal parse PaymentFailedPayload: %v", err)
-		return err
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse PaymentFailedPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	log.Printf("📨 Payment gagal diterima: OrderID=%s, Reason=%s",
+	correlation.Logf(ctx, "📨 Payment gagal diterima: OrderID=%s, Reason=%s",
 		payload.OrderID, payload.Reason)
 
 	orderID, err := uuid.Parse(payload.OrderID)
 	if err != nil {
-		return err
+		return broker.Permanent(err)
 	}
 
-	if err := oc.repo.UpdateStatus(orderID, repository.CANCELLED); err != nil {
-		log.Printf("❌ Gagal update order status ke CANCELLED: %v", err)
-		return err
+	_, err = oc.svc.CancelOrder(ctx, orderID, "pembayaran gagal: "+payload.Reason, eventActor(d))
+	return handleStatusError(ctx, err)
+}
+func (oc *OrderConsumer) handlePaymentRefunded(ctx context.Context, d *broker.Delivery) error {
+
+	var payload broker.PaymentRefundedPayload
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse PaymentRefundedPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	log.Printf("✅ Order %s status diperbarui: PENDING → CANCELLED (payment failed)", payload.OrderID)
-	return nil
+	correlation.Logf(ctx, "📨 Refund diterima: OrderID=%s, Amount=%s, Total=%s, Penuh=%v, Reason=%s",
+		payload.OrderID, payload.Amount, payload.TotalRefunded, payload.FullyRefunded, payload.Reason)
+
+	orderID, err := uuid.Parse(payload.OrderID)
+	if err != nil {
+		return broker.Permanent(err)
+	}
+
+	err = oc.svc.RecordRefund(ctx, orderID, payload.TotalRefunded)
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound), errors.Is(err, money.ErrCurrencyMismatch):
+		return broker.Permanent(err)
+	}
+	return err
 }
-func (oc *OrderConsumer) handleStockFailed(event broker.Event) error {
+
+func (oc *OrderConsumer) handleStockFailed(ctx context.Context, d *broker.Delivery) error {
 
 	var payload broker.StockFailedPayload
-	if err := json.Unmarshal(event.Payload, &payload); err != nil {
-		log.Printf("❌ Gagal parse StockFailedPayload: %v", err)
-		return err
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse StockFailedPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	log.Printf("📨 Stock gagal diterima: OrderID=%s, Item=%s, Reason=%s",
-		payload.OrderID, payload.ItemName, payload.Reason)
+	correlation.Logf(ctx, "📨 Stock gagal diterima: OrderID=%s, Reason=%s", payload.OrderID, payload.Reason)
+	for _, item := range payload.Items {
+		correlation.Logf(ctx, "   ↳ Item=%s, Diminta=%d, Tersedia=%d, Reason=%s",
+			item.ItemName, item.Requested, item.Available, item.Reason)
+	}
 
 	orderID, err := uuid.Parse(payload.OrderID)
 	if err != nil {
-		return err
+		return broker.Permanent(err)
 	}
 
-	if err := oc.repo.UpdateStatus(orderID, repository.CANCELLED); err != nil {
-		log.Printf("❌ Gagal update order status ke CANCELLED: %v", err)
-		return err
+	_, err = oc.svc.CancelOrder(ctx, orderID, "stok tidak tersedia: "+payload.Reason, eventActor(d))
+	return handleStatusError(ctx, err)
+}
+
+func (oc *OrderConsumer) handleShipmentCreated(ctx context.Context, d *broker.Delivery) error {
+
+	var payload broker.ShipmentCreatedPayload
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse ShipmentCreatedPayload: %v", err)
+		return broker.Permanent(err)
+	}
+
+	correlation.Logf(ctx, "📨 Shipment dibuat diterima: OrderID=%s, ShipmentID=%d, Kurir=%s",
+		payload.OrderID, payload.ShipmentID, payload.CourierName)
+
+	// Shipment tanpa kurir belum dikirim; order menjadi SHIPPED saat kurir
+	// ditugaskan (shipment.status_updated ke PICKING_UP)
+	if payload.Status == shipmentPendingAssignment {
+		return nil
+	}
+
+	orderID, err := uuid.Parse(payload.OrderID)
+	if err != nil {
+		return broker.Permanent(err)
+	}
+
+	_, err = oc.svc.AdvanceOrderStatus(ctx, orderID, repository.SHIPPED, eventActor(d))
+	return handleStatusError(ctx, err)
+}
+
+func (oc *OrderConsumer) handleShipmentStatusUpdated(ctx context.Context, d *broker.Delivery) error {
+
+	var payload broker.ShipmentStatusUpdatedPayload
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse ShipmentStatusUpdatedPayload: %v", err)
+		return broker.Permanent(err)
+	}
+
+	correlation.Logf(ctx, "📨 Status shipment diterima: OrderID=%s, ShipmentID=%d, %s → %s",
+		payload.OrderID, payload.ShipmentID, payload.FromStatus, payload.ToStatus)
+
+	// Hanya penugasan kurir dan shipment yang sudah sampai yang mengubah status
+	// order; status perjalanan lain cukup terlihat di delivery service.
+	var status repository.OrderStatus
+	switch {
+	case payload.FromStatus == shipmentPendingAssignment && payload.ToStatus == shipmentPickingUp:
+		status = repository.SHIPPED
+	case payload.ToStatus == shipmentDelivered:
+		status = repository.DELIVERED
+	default:
+		return nil
+	}
+
+	orderID, err := uuid.Parse(payload.OrderID)
+	if err != nil {
+		return broker.Permanent(err)
 	}
 
-	log.Printf("✅ Order %s status diperbarui: PENDING → CANCELLED (stock failed)", payload.OrderID) // Log
-	return nil                                                                                      // Sukses
+	// DELIVERED yang diproses sebelum SHIPPED ikut mencatat SHIPPED; order
+	// yang belum PAID dicoba lagi lewat handleStatusError
+	_, err = oc.svc.AdvanceOrderStatus(ctx, orderID, status, eventActor(d))
+	return handleStatusError(ctx, err)
+}
+
+// eventActor menandai perubahan status yang dipicu event di order_status_history.
+func eventActor(d *broker.Delivery) string {
+	return "event:" + string(d.Event.Type)
+}
+
+// handleStatusError menentukan nasib pesan setelah perubahan status order.
+// Order yang masih di belakang status tujuan (event datang lebih cepat dari
+// event sebelumnya di queue lain) dicoba lagi. Transisi lain yang tidak
+// diizinkan (misal payment.success yang datang setelah order dibatalkan)
+// tidak akan berhasil walau diulang, jadi event di-ack.
+func handleStatusError(ctx context.Context, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrStatusNotReached):
+		correlation.Logf(ctx, "⏳ Order belum siap, event dicoba lagi: %v", err)
+		return err
+	case errors.Is(err, service.ErrInvalidStatusTransition):
+		correlation.Logf(ctx, "⚠️ Perubahan status ditolak, event diabaikan: %v", err)
+		return nil
+	case errors.Is(err, service.ErrOrderNotFound):
+		return broker.Permanent(err)
+	}
+	return err
 }
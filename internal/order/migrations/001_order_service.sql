@@ -2,14 +2,22 @@
 -- Database: db_order
 
 -- Create ENUM type for order status
-CREATE TYPE order_status AS ENUM ('PENDING', 'PAID', 'SHIPPED', 'CANCELLED');
+CREATE TYPE order_status AS ENUM ('PENDING', 'PAID', 'SHIPPED', 'DELIVERED', 'CANCELLED');
 
 -- Create orders table
 CREATE TABLE IF NOT EXISTS orders (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     user_id VARCHAR(255) NOT NULL,
     total_price DECIMAL(12, 2) NOT NULL DEFAULT 0.00,
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
     status order_status NOT NULL DEFAULT 'PENDING',
+    delivery_address TEXT,
+    destination_lat DECIMAL(10, 8),
+    destination_long DECIMAL(11, 8),
+    cancellation_reason TEXT,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    refunded_amount DECIMAL(12, 2) NOT NULL DEFAULT 0.00,
+    refunded_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
@@ -19,6 +27,31 @@ CREATE INDEX idx_orders_user_id ON orders(user_id);
 CREATE INDEX idx_orders_status ON orders(status);
 CREATE INDEX idx_orders_created_at ON orders(created_at DESC);
 
+-- Create order_items table (satu baris per produk dalam order)
+CREATE TABLE IF NOT EXISTS order_items (
+    id SERIAL PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    item_name VARCHAR(255) NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(12, 2) NOT NULL DEFAULT 0.00
+);
+
+CREATE INDEX idx_order_items_order_id ON order_items(order_id);
+
+-- Create order_status_history table (setiap perubahan status order beserta pemicunya)
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id SERIAL PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    event_id VARCHAR(64),
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_order_status_history_order_id ON order_status_history(order_id);
+
 -- Create trigger to update updated_at automatically
 CREATE OR REPLACE FUNCTION update_updated_at_column()
 RETURNS TRIGGER AS $$
@@ -32,3 +65,31 @@ CREATE TRIGGER update_orders_updated_at
     BEFORE UPDATE ON orders
     FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
+
+-- Create outbox table (transactional outbox untuk event order.*)
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id SERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    body TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE,
+    failed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Dipakai relay untuk mencari pesan yang belum terkirim; pesan rusak (failed_at) tidak ikut
+CREATE INDEX idx_outbox_messages_next_attempt_at ON outbox_messages(next_attempt_at) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_messages_published_at ON outbox_messages(published_at);
+
+-- Create processed_events table (event yang sudah diproses per queue, untuk idempotency consumer)
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(64) NOT NULL,
+    consumer VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, consumer)
+);
+
+CREATE INDEX idx_processed_events_processed_at ON processed_events(processed_at);
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
 	"gorm.io/gorm"
 )
 
@@ -14,12 +15,13 @@ const (
 	PENDING   OrderStatus = "PENDING"
 	PAID      OrderStatus = "PAID"
 	SHIPPED   OrderStatus = "SHIPPED"
+	DELIVERED OrderStatus = "DELIVERED"
 	CANCELLED OrderStatus = "CANCELLED"
 )
 
 func (s OrderStatus) IsValid() bool {
 	switch s {
-	case PENDING, PAID, SHIPPED, CANCELLED:
+	case PENDING, PAID, SHIPPED, DELIVERED, CANCELLED:
 		return true
 	}
 	return false
@@ -28,12 +30,56 @@ func (s OrderStatus) IsValid() bool {
 type Order struct {
 	ID         uuid.UUID   `gorm:"type:uuid;primarykey;default:uuid_generate_v4()"`
 	CustomerID string      `gorm:"not null"`
-	ItemName   string      `gorm:"not null"`
-	Quantity   int         `gorm:"not null"`
-	TotalPrice float64     `gorm:"not null"`
+	Items      []OrderItem `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	TotalPrice money.Money `gorm:"type:decimal(12,2);not null"`
+	Currency   string      `gorm:"type:varchar(3);not null;default:IDR"`
 	Status     OrderStatus `gorm:"type:varchar(20);default:PENDING;not null"`
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+
+	// DeliveryAddress dan koordinatnya diteruskan ke delivery service lewat
+	// order.created sebagai tujuan pengiriman.
+	DeliveryAddress string  `gorm:"type:text"`
+	DestinationLat  float64 `gorm:"type:decimal(10,8)"`
+	DestinationLong float64 `gorm:"type:decimal(11,8)"`
+
+	CancellationReason string `gorm:"type:text"`
+	CancelledAt        *time.Time
+
+	// RefundedAmount adalah total dana yang sudah dikembalikan payment service
+	// (dari event payment.refunded); nol jika belum ada refund.
+	RefundedAmount money.Money `gorm:"type:decimal(12,2);not null;default:0"`
+	RefundedAt     *time.Time
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// OrderStatusHistory mencatat satu perubahan status order, termasuk
+// pembuatan order (FromStatus kosong).
+type OrderStatusHistory struct {
+	ID         uint        `gorm:"primaryKey"`
+	OrderID    uuid.UUID   `gorm:"type:uuid;not null;index"`
+	FromStatus OrderStatus `gorm:"type:varchar(20)"`
+	ToStatus   OrderStatus `gorm:"type:varchar(20);not null"`
+	// Actor adalah pemicu perubahan, misal "api" atau "event:payment.success".
+	Actor string `gorm:"type:varchar(100);not null"`
+	// EventID adalah ID event pemicu; kosong jika dipicu HTTP request.
+	EventID   string `gorm:"type:varchar(64)"`
+	Reason    string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+// OrderItem adalah satu baris produk dalam order.
+type OrderItem struct {
+	ID       uint      `gorm:"primaryKey"`
+	OrderID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	ItemName string    `gorm:"not null"`
+	Quantity int       `gorm:"not null;check:quantity > 0"`
+	// UnitPrice adalah harga katalog per unit saat order dibuat.
+	UnitPrice money.Money `gorm:"type:decimal(12,2);not null;default:0"`
 }
 
 func (o *Order) BeforeCreate(tx *gorm.DB) (err error) {
@@ -42,6 +88,32 @@ func (o *Order) BeforeCreate(tx *gorm.DB) (err error) {
 	if !o.Status.IsValid() {
 		return fmt.Errorf("invalid order status: %s", o.Status)
 	}
+	if o.TotalPrice.IsNegative() {
+		return fmt.Errorf("order total cannot be negative: %s", o.TotalPrice)
+	}
+	if o.Currency == "" {
+		o.Currency = o.TotalPrice.WithDefaultCurrency().Currency
+	}
+
+	return nil
+}
+
+// AfterFind mengisi currency nominal dari kolom currency order,
+// karena kolom decimal hanya menyimpan angkanya.
+func (o *Order) AfterFind(tx *gorm.DB) (err error) {
+	o.TotalPrice.Currency = o.Currency
+	o.RefundedAmount.Currency = o.Currency
+	for i := range o.Items {
+		o.Items[i].UnitPrice.Currency = o.Currency
+	}
+
+	return nil
+}
+
+func (i *OrderItem) BeforeCreate(tx *gorm.DB) (err error) {
+	if i.Quantity <= 0 {
+		return fmt.Errorf("order item quantity must be positive: %d", i.Quantity)
+	}
 
 	return nil
 }
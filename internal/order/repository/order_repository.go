@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository interface {
@@ -10,9 +15,26 @@ type OrderRepository interface {
 
 	GetByID(id uuid.UUID) (*Order, error)
 
+	// GetByIDForUpdate mengunci baris order sampai transaksi selesai.
+	GetByIDForUpdate(id uuid.UUID) (*Order, error)
+
 	GetByCustomerID(customerID string) ([]Order, error)
 
 	UpdateStatus(id uuid.UUID, status OrderStatus) error
+
+	Cancel(id uuid.UUID, reason string, cancelledAt time.Time) error
+
+	// SetRefunded mencatat total refund order; total bersifat absolut sehingga
+	// event payment.refunded yang terkirim ulang tidak menggandakan nominal.
+	SetRefunded(id uuid.UUID, total money.Money, refundedAt time.Time) error
+
+	CreateStatusHistory(history *OrderStatusHistory) error
+
+	GetStatusHistory(orderID uuid.UUID) ([]OrderStatusHistory, error)
+
+	// Transaction menjalankan fn dalam satu transaksi DB; event yang ditulis
+	// ke outbox ikut ter-commit bersama perubahan order.
+	Transaction(fn func(repo OrderRepository, outbox *broker.Outbox) error) error
 }
 
 type orderRepository struct {
@@ -29,7 +51,16 @@ func (r *orderRepository) Create(order *Order) error {
 
 func (r *orderRepository) GetByID(id uuid.UUID) (*Order, error) {
 	var order Order
-	err := r.db.First(&order, "id = ?", id).Error
+	err := r.db.Preload("Items").First(&order, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *orderRepository) GetByIDForUpdate(id uuid.UUID) (*Order, error) {
+	var order Order
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -38,7 +69,7 @@ func (r *orderRepository) GetByID(id uuid.UUID) (*Order, error) {
 
 func (r *orderRepository) GetByCustomerID(customerID string) ([]Order, error) {
 	var orders []Order
-	err := r.db.Where("customer_id = ?", customerID).Order("created_at DESC").Find(&orders).Error
+	err := r.db.Preload("Items").Where("customer_id = ?", customerID).Order("created_at DESC").Find(&orders).Error
 	if err != nil {
 		return nil, err
 	}
@@ -48,3 +79,37 @@ func (r *orderRepository) GetByCustomerID(customerID string) ([]Order, error) {
 func (r *orderRepository) UpdateStatus(id uuid.UUID, status OrderStatus) error {
 	return r.db.Model(&Order{}).Where("id = ?", id).Update("status", status).Error
 }
+
+func (r *orderRepository) Cancel(id uuid.UUID, reason string, cancelledAt time.Time) error {
+	return r.db.Model(&Order{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":              CANCELLED,
+		"cancellation_reason": reason,
+		"cancelled_at":        cancelledAt,
+	}).Error
+}
+
+func (r *orderRepository) SetRefunded(id uuid.UUID, total money.Money, refundedAt time.Time) error {
+	return r.db.Model(&Order{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"refunded_amount": total,
+		"refunded_at":     refundedAt,
+	}).Error
+}
+
+func (r *orderRepository) CreateStatusHistory(history *OrderStatusHistory) error {
+	return r.db.Create(history).Error
+}
+
+func (r *orderRepository) GetStatusHistory(orderID uuid.UUID) ([]OrderStatusHistory, error) {
+	var history []OrderStatusHistory
+	err := r.db.Where("order_id = ?", orderID).Order("created_at ASC, id ASC").Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *orderRepository) Transaction(fn func(repo OrderRepository, outbox *broker.Outbox) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&orderRepository{db: tx}, broker.NewOutbox(tx))
+	})
+}
@@ -1,71 +1,249 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/internal/order/catalog"
 	"github.com/purnama/Event-Driven-Logistic/internal/order/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/geo"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+	"gorm.io/gorm"
 )
 
 type OrderService interface {
-	CreateOrder(req CreateOrderRequest) (*repository.Order, error)
+	CreateOrder(ctx context.Context, req CreateOrderRequest) (*repository.Order, error)
 
 	GetOrderByID(id uuid.UUID) (*repository.Order, error)
 
 	GetOrdersByCustomerID(customerID string) ([]repository.Order, error)
 
-	UpdateOrderStatus(id uuid.UUID, status repository.OrderStatus) error
+	// UpdateOrderStatus mengubah status order sesuai tabel transisi dan mencatatnya
+	// di order_status_history. actor adalah pemicu perubahan (ActorAPI atau event).
+	UpdateOrderStatus(ctx context.Context, id uuid.UUID, status repository.OrderStatus, actor string) (*repository.Order, error)
+
+	// AdvanceOrderStatus memajukan order yang sudah PAID sampai status tujuan
+	// (SHIPPED atau DELIVERED) dan mencatat setiap status antara yang terlewat,
+	// misal shipment DELIVERED yang diproses sebelum event SHIPPED. Order yang
+	// belum PAID mengembalikan ErrStatusNotReached karena PAID hanya boleh
+	// berasal dari payment.success.
+	AdvanceOrderStatus(ctx context.Context, id uuid.UUID, status repository.OrderStatus, actor string) (*repository.Order, error)
+
+	// CancelOrder membatalkan order dan mengirim order.cancelled.
+	CancelOrder(ctx context.Context, id uuid.UUID, reason string, actor string) (*repository.Order, error)
+
+	GetStatusHistory(id uuid.UUID) ([]repository.OrderStatusHistory, error)
+
+	// RecordRefund mencatat total dana yang sudah dikembalikan untuk order.
+	// Total yang lebih kecil dari catatan saat ini (event lama) diabaikan.
+	RecordRefund(ctx context.Context, id uuid.UUID, totalRefunded money.Money) error
 }
 
+// ActorAPI adalah actor untuk perubahan status yang dipicu HTTP API.
+const ActorAPI = "api"
+
+var (
+	ErrOrderNotFound = errors.New("order tidak ditemukan")
+
+	ErrInvalidStatusTransition = errors.New("perubahan status order tidak diizinkan")
+
+	// ErrStatusNotReached membungkus ErrInvalidStatusTransition jika order masih
+	// di belakang status tujuan pada alur normal (misal shipment.created yang
+	// datang sebelum payment.success). Berbeda dengan order yang sudah CANCELLED
+	// atau sudah melewati tujuan, transisi ini bisa berhasil jika dicoba lagi.
+	ErrStatusNotReached = errors.New("order belum mencapai status sebelumnya")
+
+	// ErrUnknownProduct dikembalikan jika item tidak ada di katalog atau belum memiliki harga.
+	ErrUnknownProduct = errors.New("produk tidak tersedia di katalog")
+
+	// ErrMixedCurrency dikembalikan jika item dalam satu order memiliki currency berbeda.
+	ErrMixedCurrency = errors.New("item order memiliki currency berbeda")
+
+	// ErrInvalidAddress dikembalikan jika alamat pengiriman kosong atau koordinatnya tidak valid.
+	ErrInvalidAddress = errors.New("alamat pengiriman tidak valid")
+)
+
+// CreateOrderRequest tidak menerima harga dari client; total dihitung dari
+// harga katalog inventory saat order dibuat.
 type CreateOrderRequest struct {
-	CustomerID string  `json:"customer_id" binding:"required"`
-	ItemName   string  `json:"item_name" binding:"required"`
-	Quantity   int     `json:"quantity" binding:"required,gt=0"`
-	TotalPrice float64 `json:"total_price" binding:"required,gt=0"`
+	CustomerID string             `json:"customer_id" binding:"required"`
+	Items      []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	// DeliveryAddress adalah tujuan pengiriman, dipakai delivery service untuk menghitung ETA.
+	DeliveryAddress DeliveryAddressRequest `json:"delivery_address" binding:"required"`
+}
+
+// DeliveryAddressRequest adalah alamat pengiriman beserta koordinatnya.
+type DeliveryAddressRequest struct {
+	Address string   `json:"address" binding:"required"`
+	Lat     *float64 `json:"lat" binding:"required"`
+	Long    *float64 `json:"long" binding:"required"`
+}
+
+// OrderItemRequest adalah satu baris produk pada CreateOrderRequest.
+type OrderItemRequest struct {
+	ItemName string `json:"item_name" binding:"required"`
+	Quantity int    `json:"quantity" binding:"required,gt=0"`
 }
 
 type orderService struct {
-	repo repository.OrderRepository
+	repo    repository.OrderRepository
+	catalog catalog.Catalog
 }
 
-func NewOrderService(repo repository.OrderRepository) OrderService {
-	return &orderService{repo: repo}
+func NewOrderService(repo repository.OrderRepository, catalog catalog.Catalog) OrderService {
+	return &orderService{repo: repo, catalog: catalog}
 }
 
-func (s *orderService) CreateOrder(req CreateOrderRequest) (*repository.Order, error) {
+func (s *orderService) CreateOrder(ctx context.Context, req CreateOrderRequest) (*repository.Order, error) {
 
-	if req.Quantity <= 0 {
-		return nil, errors.New("quantity harus lebih dari 0")
+	if len(req.Items) == 0 {
+		return nil, errors.New("order harus memiliki minimal 1 item")
 	}
 
-	if req.TotalPrice <= 0 {
-		return nil, errors.New("total price harus lebih dari 0")
+	items := make([]repository.OrderItem, 0, len(req.Items))
+	seen := make(map[string]bool, len(req.Items))
+	for _, item := range req.Items {
+		if item.ItemName == "" {
+			return nil, errors.New("nama item tidak boleh kosong")
+		}
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity item '%s' harus lebih dari 0", item.ItemName)
+		}
+		// Satu produk satu baris, karena inventory membuat satu reservasi per produk
+		if seen[item.ItemName] {
+			return nil, fmt.Errorf("item '%s' muncul lebih dari sekali, gabungkan quantity-nya", item.ItemName)
+		}
+		seen[item.ItemName] = true
+		items = append(items, repository.OrderItem{ItemName: item.ItemName, Quantity: item.Quantity})
 	}
 
 	if req.CustomerID == "" {
 		return nil, errors.New("customer_id tidak boleh kosong")
 	}
 
+	destination, err := validateAddress(req.DeliveryAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.priceItems(ctx, items)
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal menghitung harga order: %v", err)
+		return nil, err
+	}
+
 	order := &repository.Order{
 		CustomerID: req.CustomerID,
-		ItemName:   req.ItemName,
-		Quantity:   req.Quantity,
-		TotalPrice: req.TotalPrice,
+		Items:      items,
+		TotalPrice: total,
+		Currency:   total.Currency,
 		Status:     repository.PENDING,
+
+		DeliveryAddress: req.DeliveryAddress.Address,
+		DestinationLat:  destination.Lat,
+		DestinationLong: destination.Long,
 	}
 
-	if err := s.repo.Create(order); err != nil {
-		log.Printf("❌ Gagal membuat order: %v", err)
+	err = s.repo.Transaction(func(repo repository.OrderRepository, outbox *broker.Outbox) error {
+		if err := repo.Create(order); err != nil {
+			return err
+		}
+
+		if err := repo.CreateStatusHistory(&repository.OrderStatusHistory{
+			OrderID:  order.ID,
+			ToStatus: order.Status,
+			Actor:    ActorAPI,
+		}); err != nil {
+			return err
+		}
+
+		payloadItems := make([]broker.OrderItemPayload, 0, len(order.Items))
+		for _, item := range order.Items {
+			payloadItems = append(payloadItems, broker.OrderItemPayload{
+				ItemName:  item.ItemName,
+				Quantity:  item.Quantity,
+				UnitPrice: item.UnitPrice,
+			})
+		}
+
+		return outbox.EnqueueEventWithContext(ctx, broker.OrderCreated, broker.OrderCreatedPayload{
+			OrderID:    order.ID.String(),
+			CustomerID: order.CustomerID,
+			Items:      payloadItems,
+			TotalPrice: order.TotalPrice,
+			DeliveryAddress: &broker.DeliveryAddressPayload{
+				Address: order.DeliveryAddress,
+				Lat:     order.DestinationLat,
+				Long:    order.DestinationLong,
+			},
+		})
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal membuat order: %v", err)
 		return nil, err
 	}
 
-	log.Printf("✅ Order berhasil dibuat: ID=%s, Customer=%s, Item=%s",
-		order.ID, order.CustomerID, order.ItemName)
+	correlation.Logf(ctx, "✅ Order berhasil dibuat: ID=%s, Customer=%s, Items=%d, Total=%s",
+		order.ID, order.CustomerID, len(order.Items), order.TotalPrice)
 
 	return order, nil
 }
 
+// validateAddress memastikan alamat pengiriman terisi dan koordinatnya sah.
+func validateAddress(req DeliveryAddressRequest) (geo.Point, error) {
+	if strings.TrimSpace(req.Address) == "" {
+		return geo.Point{}, fmt.Errorf("%w: alamat tidak boleh kosong", ErrInvalidAddress)
+	}
+	if req.Lat == nil || req.Long == nil {
+		return geo.Point{}, fmt.Errorf("%w: lat dan long wajib diisi", ErrInvalidAddress)
+	}
+	point := geo.Point{Lat: *req.Lat, Long: *req.Long}
+	if err := point.Validate(); err != nil {
+		return geo.Point{}, fmt.Errorf("%w: %v", ErrInvalidAddress, err)
+	}
+	return point, nil
+}
+
+// priceItems mengisi UnitPrice setiap item dari katalog dan mengembalikan total order.
+func (s *orderService) priceItems(ctx context.Context, items []repository.OrderItem) (money.Money, error) {
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.ItemName)
+	}
+
+	prices, err := s.catalog.GetPrices(ctx, names)
+	if err != nil {
+		return money.Money{}, err
+	}
+
+	var total money.Money
+	for i, item := range items {
+		price, ok := prices[item.ItemName]
+		if !ok || !price.Price.IsPositive() {
+			return money.Money{}, fmt.Errorf("%w: %s", ErrUnknownProduct, item.ItemName)
+		}
+
+		unitPrice := price.Price.WithDefaultCurrency()
+		total, err = total.Add(unitPrice.Mul(int64(item.Quantity)))
+		if errors.Is(err, money.ErrCurrencyMismatch) {
+			return money.Money{}, fmt.Errorf("%w: %s (%v)", ErrMixedCurrency, item.ItemName, err)
+		}
+		if err != nil {
+			return money.Money{}, err
+		}
+		items[i].UnitPrice = unitPrice
+	}
+
+	return total, nil
+}
+
 func (s *orderService) GetOrderByID(id uuid.UUID) (*repository.Order, error) {
 	order, err := s.repo.GetByID(id)
 	if err != nil {
@@ -88,32 +266,250 @@ func (s *orderService) GetOrdersByCustomerID(customerID string) ([]repository.Or
 	return orders, nil
 }
 
-func (s *orderService) UpdateOrderStatus(id uuid.UUID, status repository.OrderStatus) error {
+func (s *orderService) UpdateOrderStatus(ctx context.Context, id uuid.UUID, status repository.OrderStatus, actor string) (*repository.Order, error) {
 
 	if !status.IsValid() {
-		return errors.New("status order tidak valid: " + string(status))
+		return nil, errors.New("status order tidak valid: " + string(status))
+	}
+
+	if status == repository.CANCELLED {
+		return nil, fmt.Errorf("%w: gunakan CancelOrder untuk membatalkan order", ErrInvalidStatusTransition)
+	}
+
+	var updated *repository.Order
+	var previousStatus repository.OrderStatus
+	err := s.repo.Transaction(func(repo repository.OrderRepository, outbox *broker.Outbox) error {
+		order, err := changeStatus(ctx, repo, id, status, actor, "")
+		if err != nil {
+			return err
+		}
+		updated, previousStatus = order, order.Status
+		updated.Status = status
+		return nil
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal update status order: ID=%s, error=%v", id, err)
+		return nil, err
+	}
+
+	correlation.Logf(ctx, "✅ Status order diperbarui: ID=%s, %s → %s (actor=%s)",
+		id, previousStatus, status, actor)
+
+	return updated, nil
+}
+
+func (s *orderService) AdvanceOrderStatus(ctx context.Context, id uuid.UUID, status repository.OrderStatus, actor string) (*repository.Order, error) {
+
+	if flowIndex(status) <= flowIndex(repository.PAID) {
+		return nil, fmt.Errorf("%w: %s bukan status pengiriman", ErrInvalidStatusTransition, status)
 	}
 
-	currentOrder, err := s.repo.GetByID(id)
+	var updated *repository.Order
+	var previousStatus repository.OrderStatus
+	err := s.repo.Transaction(func(repo repository.OrderRepository, outbox *broker.Outbox) error {
+		order, err := repo.GetByIDForUpdate(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrOrderNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Status antara yang terlewat dicatat satu per satu. PAID tidak pernah
+		// dilompati; changeStatus di bawah lalu mengembalikan ErrStatusNotReached
+		for i := flowIndex(order.Status) + 1; i > 0 && i < flowIndex(status); i++ {
+			if orderFlow[i] == repository.PAID {
+				break
+			}
+			if _, err := changeStatus(ctx, repo, id, orderFlow[i], actor, ""); err != nil {
+				return err
+			}
+		}
+
+		if _, err := changeStatus(ctx, repo, id, status, actor, ""); err != nil {
+			return err
+		}
+		updated, previousStatus = order, order.Status
+		updated.Status = status
+		return nil
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal update status order: ID=%s, error=%v", id, err)
+		return nil, err
+	}
+
+	correlation.Logf(ctx, "✅ Status order diperbarui: ID=%s, %s → %s (actor=%s)",
+		id, previousStatus, status, actor)
+
+	return updated, nil
+}
+
+func (s *orderService) CancelOrder(ctx context.Context, id uuid.UUID, reason string, actor string) (*repository.Order, error) {
+
+	if reason == "" {
+		return nil, errors.New("alasan pembatalan tidak boleh kosong")
+	}
+
+	var cancelled *repository.Order
+	err := s.repo.Transaction(func(repo repository.OrderRepository, outbox *broker.Outbox) error {
+		order, err := changeStatus(ctx, repo, id, repository.CANCELLED, actor, reason)
+		if err != nil {
+			return err
+		}
+
+		previousStatus := order.Status
+		now := time.Now()
+		order.Status = repository.CANCELLED
+		order.CancellationReason = reason
+		order.CancelledAt = &now
+		cancelled = order
+
+		return outbox.EnqueueEventWithContext(ctx, broker.OrderCancelled, broker.OrderCancelledPayload{
+			OrderID:        order.ID.String(),
+			CustomerID:     order.CustomerID,
+			PreviousStatus: string(previousStatus),
+			Reason:         reason,
+		})
+	})
 	if err != nil {
-		return errors.New("order tidak ditemukan: " + id.String())
+		correlation.Logf(ctx, "❌ Gagal membatalkan order: ID=%s, error=%v", id, err)
+		return nil, err
 	}
 
-	if currentOrder.Status == repository.CANCELLED {
-		return errors.New("tidak dapat mengubah status order yang sudah CANCELLED")
+	correlation.Logf(ctx, "🚫 Order dibatalkan: ID=%s, Reason=%s (actor=%s)", id, reason, actor)
+	return cancelled, nil
+}
+
+func (s *orderService) GetStatusHistory(id uuid.UUID) ([]repository.OrderStatusHistory, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, id)
+		}
+		return nil, err
 	}
 
-	if currentOrder.Status == repository.SHIPPED && status == repository.PENDING {
-		return errors.New("tidak dapat mengembalikan status SHIPPED ke PENDING")
+	history, err := s.repo.GetStatusHistory(id)
+	if err != nil {
+		log.Printf("❌ Gagal mengambil riwayat status order: ID=%s, error=%v", id, err)
+		return nil, err
 	}
+	return history, nil
+}
 
-	if err := s.repo.UpdateStatus(id, status); err != nil {
-		log.Printf("❌ Gagal update status order: ID=%s, error=%v", id, err)
+func (s *orderService) RecordRefund(ctx context.Context, id uuid.UUID, totalRefunded money.Money) error {
+	err := s.repo.Transaction(func(repo repository.OrderRepository, outbox *broker.Outbox) error {
+		order, err := repo.GetByIDForUpdate(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrOrderNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		diff, err := totalRefunded.Sub(order.RefundedAmount)
+		if err != nil {
+			return err
+		}
+		if !diff.IsPositive() {
+			correlation.Logf(ctx, "⏭️ Refund order %s sudah tercatat: %s", id, order.RefundedAmount)
+			return nil
+		}
+
+		return repo.SetRefunded(id, totalRefunded, time.Now())
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal mencatat refund order: ID=%s, error=%v", id, err)
 		return err
 	}
 
-	log.Printf("✅ Status order diperbarui: ID=%s, %s → %s",
-		id, currentOrder.Status, status)
+	correlation.Logf(ctx, "💸 Refund order dicatat: ID=%s, TotalRefund=%s", id, totalRefunded)
+	return nil
+}
+
+// changeStatus adalah satu-satunya jalur perubahan status order, dipakai oleh
+// HTTP maupun consumer. Harus dipanggil di dalam repo.Transaction: baris order
+// dikunci, transisi divalidasi, status diubah dan riwayatnya dicatat.
+// Order yang dikembalikan masih berisi status lama.
+func changeStatus(ctx context.Context, repo repository.OrderRepository, id uuid.UUID,
+	to repository.OrderStatus, actor, reason string) (*repository.Order, error) {
+
+	order, err := repo.GetByIDForUpdate(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !isValidStatusTransition(order.Status, to) {
+		if isBehind(order.Status, to) {
+			return nil, fmt.Errorf("%w: %w: %s → %s", ErrInvalidStatusTransition, ErrStatusNotReached, order.Status, to)
+		}
+		return nil, fmt.Errorf("%w: %s → %s", ErrInvalidStatusTransition, order.Status, to)
+	}
+
+	if to == repository.CANCELLED {
+		err = repo.Cancel(id, reason, time.Now())
+	} else {
+		err = repo.UpdateStatus(id, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.CreateStatusHistory(&repository.OrderStatusHistory{
+		OrderID:    id,
+		FromStatus: order.Status,
+		ToStatus:   to,
+		Actor:      actor,
+		EventID:    correlation.CausationID(ctx),
+		Reason:     reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// orderFlow adalah urutan status order pada alur normal; CANCELLED tidak
+// termasuk karena merupakan status akhir.
+var orderFlow = []repository.OrderStatus{repository.PENDING, repository.PAID, repository.SHIPPED, repository.DELIVERED}
+
+// flowIndex mengembalikan posisi status di orderFlow, atau -1 untuk CANCELLED.
+func flowIndex(status repository.OrderStatus) int {
+	for i, s := range orderFlow {
+		if s == status {
+			return i
+		}
+	}
+	return -1
+}
+
+// isBehind bernilai true jika order berstatus from masih bisa mencapai to
+// pada alur normal, yaitu from belum CANCELLED dan ada sebelum to.
+func isBehind(from, to repository.OrderStatus) bool {
+	i, j := flowIndex(from), flowIndex(to)
+	return i >= 0 && j >= 0 && i < j
+}
+
+// isValidStatusTransition adalah tabel transisi status order.
+func isValidStatusTransition(from, to repository.OrderStatus) bool {
+	validTransitions := map[repository.OrderStatus][]repository.OrderStatus{
+		repository.PENDING: {repository.PAID, repository.CANCELLED},
+		repository.PAID:    {repository.SHIPPED, repository.CANCELLED},
+		repository.SHIPPED: {repository.DELIVERED},
+	}
 
-	return nil 
+	allowed, exists := validTransitions[from]
+	if !exists {
+		return false
+	}
+
+	for _, s := range allowed {
+		if s == to {
+			return true
+		}
+	}
+	return false
 }
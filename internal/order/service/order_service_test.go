@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/internal/order/catalog"
+	"github.com/purnama/Event-Driven-Logistic/internal/order/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+	"gorm.io/gorm"
+)
+
+type fakeCatalog map[string]catalog.ProductPrice
+
+func (f fakeCatalog) GetPrices(ctx context.Context, names []string) (map[string]catalog.ProductPrice, error) {
+	return f, nil
+}
+
+func TestPriceItems(t *testing.T) {
+	svc := &orderService{catalog: fakeCatalog{
+		"Nasi Goreng":  {Name: "Nasi Goreng", Price: money.New(2500000, "IDR"), Currency: "IDR"},
+		"Es Teh Manis": {Name: "Es Teh Manis", Price: money.New(500050, "IDR"), Currency: "IDR"},
+		"Kopi":         {Name: "Kopi", Price: money.New(300, "USD"), Currency: "USD"},
+		"Gratis":       {Name: "Gratis", Price: money.New(0, "IDR"), Currency: "IDR"},
+	}}
+
+	items := []repository.OrderItem{
+		{ItemName: "Nasi Goreng", Quantity: 2},
+		{ItemName: "Es Teh Manis", Quantity: 3},
+	}
+	total, err := svc.priceItems(context.Background(), items)
+	if err != nil {
+		t.Fatalf("priceItems error: %v", err)
+	}
+	if total != money.New(6500150, "IDR") {
+		t.Errorf("total = %s, want IDR 65001.50", total)
+	}
+	if items[0].UnitPrice != money.New(2500000, "IDR") || items[1].UnitPrice != money.New(500050, "IDR") {
+		t.Errorf("unit price tidak terisi: %+v", items)
+	}
+
+	tests := []struct {
+		name string
+		item string
+		want error
+	}{
+		{"tidak ada di katalog", "Pizza", ErrUnknownProduct},
+		{"belum ada harga", "Gratis", ErrUnknownProduct},
+		{"currency berbeda", "Kopi", ErrMixedCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := []repository.OrderItem{{ItemName: "Nasi Goreng", Quantity: 1}, {ItemName: tt.item, Quantity: 1}}
+			if _, err := svc.priceItems(context.Background(), items); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidStatusTransition(t *testing.T) {
+	tests := []struct {
+		from, to repository.OrderStatus
+		want     bool
+	}{
+		{repository.PENDING, repository.PAID, true},
+		{repository.PENDING, repository.CANCELLED, true},
+		{repository.PENDING, repository.SHIPPED, false},
+		{repository.PAID, repository.SHIPPED, true},
+		{repository.PAID, repository.CANCELLED, true},
+		{repository.PAID, repository.PENDING, false},
+		{repository.SHIPPED, repository.DELIVERED, true},
+		{repository.SHIPPED, repository.CANCELLED, false},
+		{repository.PAID, repository.DELIVERED, false},
+		{repository.DELIVERED, repository.SHIPPED, false},
+		{repository.CANCELLED, repository.PAID, false},
+		{repository.PAID, repository.PAID, false},
+	}
+	for _, tt := range tests {
+		if got := isValidStatusTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s → %s = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// fakeOrderRepo menyimpan order di memori untuk menguji changeStatus.
+type fakeOrderRepo struct {
+	repository.OrderRepository
+	orders  map[uuid.UUID]*repository.Order
+	history []repository.OrderStatusHistory
+}
+
+func (r *fakeOrderRepo) GetByIDForUpdate(id uuid.UUID) (*repository.Order, error) {
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *order
+	return &copied, nil
+}
+
+func (r *fakeOrderRepo) UpdateStatus(id uuid.UUID, status repository.OrderStatus) error {
+	r.orders[id].Status = status
+	return nil
+}
+
+func (r *fakeOrderRepo) Cancel(id uuid.UUID, reason string, cancelledAt time.Time) error {
+	r.orders[id].Status = repository.CANCELLED
+	r.orders[id].CancellationReason = reason
+	return nil
+}
+
+func (r *fakeOrderRepo) CreateStatusHistory(history *repository.OrderStatusHistory) error {
+	r.history = append(r.history, *history)
+	return nil
+}
+
+// Transaction tidak memakai outbox; pemanggil yang menulis event tidak diuji di sini.
+func (r *fakeOrderRepo) Transaction(fn func(repo repository.OrderRepository, outbox *broker.Outbox) error) error {
+	return fn(r, nil)
+}
+
+func TestChangeStatus(t *testing.T) {
+	id := uuid.New()
+	repo := &fakeOrderRepo{orders: map[uuid.UUID]*repository.Order{
+		id: {ID: id, Status: repository.CANCELLED},
+	}}
+	ctx := correlation.WithCausationID(context.Background(), "event-1")
+
+	// payment.success yang terlambat tidak boleh mengubah order CANCELLED
+	if _, err := changeStatus(ctx, repo, id, repository.PAID, "event:payment.success", ""); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Fatalf("err = %v, want ErrInvalidStatusTransition", err)
+	}
+	if repo.orders[id].Status != repository.CANCELLED || len(repo.history) != 0 {
+		t.Fatalf("order berubah: status=%s, history=%d", repo.orders[id].Status, len(repo.history))
+	}
+
+	repo.orders[id].Status = repository.PENDING
+	order, err := changeStatus(ctx, repo, id, repository.PAID, "event:payment.success", "")
+	if err != nil {
+		t.Fatalf("changeStatus error: %v", err)
+	}
+	if order.Status != repository.PENDING || repo.orders[id].Status != repository.PAID {
+		t.Errorf("status = %s/%s, want PENDING (lama) / PAID", order.Status, repo.orders[id].Status)
+	}
+
+	want := repository.OrderStatusHistory{
+		OrderID:    id,
+		FromStatus: repository.PENDING,
+		ToStatus:   repository.PAID,
+		Actor:      "event:payment.success",
+		EventID:    "event-1",
+	}
+	if len(repo.history) != 1 || repo.history[0] != want {
+		t.Errorf("history = %+v, want %+v", repo.history, want)
+	}
+
+	// Order yang belum PAID belum bisa SHIPPED, tapi bisa jika dicoba lagi nanti
+	repo.orders[id].Status = repository.PENDING
+	_, err = changeStatus(ctx, repo, id, repository.SHIPPED, "event:shipment.created", "")
+	if !errors.Is(err, ErrInvalidStatusTransition) || !errors.Is(err, ErrStatusNotReached) {
+		t.Errorf("PENDING → SHIPPED err = %v, want ErrInvalidStatusTransition dan ErrStatusNotReached", err)
+	}
+
+	// Order yang sudah melewati tujuan atau CANCELLED tidak akan pernah sampai
+	for _, from := range []repository.OrderStatus{repository.DELIVERED, repository.CANCELLED} {
+		repo.orders[id].Status = from
+		_, err = changeStatus(ctx, repo, id, repository.SHIPPED, "event:shipment.created", "")
+		if !errors.Is(err, ErrInvalidStatusTransition) || errors.Is(err, ErrStatusNotReached) {
+			t.Errorf("%s → SHIPPED err = %v, want hanya ErrInvalidStatusTransition", from, err)
+		}
+	}
+
+	if _, err := changeStatus(ctx, repo, uuid.New(), repository.PAID, ActorAPI, ""); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestAdvanceOrderStatus(t *testing.T) {
+	id := uuid.New()
+	repo := &fakeOrderRepo{orders: map[uuid.UUID]*repository.Order{
+		id: {ID: id, Status: repository.PENDING},
+	}}
+	svc := NewOrderService(repo, nil)
+	ctx := context.Background()
+
+	// Order yang belum PAID dicoba lagi nanti, tanpa mengubah apa pun
+	_, err := svc.AdvanceOrderStatus(ctx, id, repository.DELIVERED, "event:shipment.status_updated")
+	if !errors.Is(err, ErrStatusNotReached) {
+		t.Fatalf("err = %v, want ErrStatusNotReached", err)
+	}
+	if repo.orders[id].Status != repository.PENDING || len(repo.history) != 0 {
+		t.Fatalf("order berubah: status=%s, history=%d", repo.orders[id].Status, len(repo.history))
+	}
+
+	// DELIVERED yang datang sebelum SHIPPED ikut mencatat SHIPPED
+	repo.orders[id].Status = repository.PAID
+	order, err := svc.AdvanceOrderStatus(ctx, id, repository.DELIVERED, "event:shipment.status_updated")
+	if err != nil {
+		t.Fatalf("AdvanceOrderStatus error: %v", err)
+	}
+	if order.Status != repository.DELIVERED || repo.orders[id].Status != repository.DELIVERED {
+		t.Errorf("status = %s/%s, want DELIVERED", order.Status, repo.orders[id].Status)
+	}
+	if len(repo.history) != 2 ||
+		repo.history[0].FromStatus != repository.PAID || repo.history[0].ToStatus != repository.SHIPPED ||
+		repo.history[1].FromStatus != repository.SHIPPED || repo.history[1].ToStatus != repository.DELIVERED {
+		t.Errorf("history = %+v, want PAID → SHIPPED → DELIVERED", repo.history)
+	}
+
+	// SHIPPED yang terlambat setelah DELIVERED tetap ditolak
+	_, err = svc.AdvanceOrderStatus(ctx, id, repository.SHIPPED, "event:shipment.created")
+	if !errors.Is(err, ErrInvalidStatusTransition) || errors.Is(err, ErrStatusNotReached) {
+		t.Errorf("DELIVERED → SHIPPED err = %v, want hanya ErrInvalidStatusTransition", err)
+	}
+
+	if _, err := svc.AdvanceOrderStatus(ctx, id, repository.PAID, ActorAPI); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("target PAID err = %v, want ErrInvalidStatusTransition", err)
+	}
+}
@@ -1,27 +1,22 @@
 package dellivery
 
 import (
-	"log"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/purnama/Event-Driven-Logistic/internal/payment/event"
 	"github.com/purnama/Event-Driven-Logistic/internal/payment/service"
-	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
 	"github.com/purnama/Event-Driven-Logistic/pkg/response"
 )
 
 type PaymentHandler struct {
-	svc       service.PaymentService
-	publisher *event.PaymentPublisher
+	svc service.PaymentService
 }
 
-func NewPaymentHandler(svc service.PaymentService, publisher *event.PaymentPublisher) *PaymentHandler {
-	return &PaymentHandler{
-		svc:       svc,
-		publisher: publisher,
-	}
+func NewPaymentHandler(svc service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{svc: svc}
 }
 
 type ConfirmPaymentRequest struct {
@@ -36,26 +31,90 @@ func (h *PaymentHandler) ConfirmPayment(c *gin.Context) {
 		return
 	}
 
-	payment, err := h.svc.ConfirmPayment(req.PaymentID)
+	payment, err := h.svc.ConfirmPayment(c.Request.Context(), req.PaymentID)
 	if err != nil {
 		response.Error(c, http.StatusBadRequest, "Gagal konfirmasi pembayaran: "+err.Error())
 		return
 	}
 
-	if h.publisher != nil {
-		go func() {
-			payload := broker.PaymentSuccessPayload{
-				OrderID:   payment.OrderID.String(),
-				PaymentID: payment.ID,
-				Amount:    payment.Amount,
-			}
-			if err := h.publisher.PublishPaymentSuccess(payload); err != nil {
-				log.Printf("⚠️ Failed to publish payment.success event: %v", err)
-			}
-		}()
+	response.Success(c, "Pembayaran berhasil dikonfirmasi", payment)
+}
+
+type FailPaymentRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h *PaymentHandler) FailPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Format ID tidak valid")
+		return
 	}
 
-	response.Success(c, "Pembayaran berhasil dikonfirmasi", payment)
+	// Body opsional; tanpa alasan dipakai alasan default
+	var req FailPaymentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, "Request tidak valid: "+err.Error())
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "pembayaran ditolak"
+	}
+
+	payment, err := h.svc.FailPayment(c.Request.Context(), uint(id), req.Reason)
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound):
+		response.Error(c, http.StatusNotFound, "Pembayaran tidak ditemukan")
+		return
+	case errors.Is(err, service.ErrPaymentNotPending):
+		response.Error(c, http.StatusConflict, "Gagal menandai pembayaran gagal: "+err.Error())
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Gagal menandai pembayaran gagal: "+err.Error())
+		return
+	}
+
+	response.Success(c, "Pembayaran ditandai gagal", payment)
+}
+
+// RefundPaymentRequest tanpa amount berarti refund penuh sisa nominal payment.
+type RefundPaymentRequest struct {
+	Amount *money.Money `json:"amount"`
+	Reason string       `json:"reason" binding:"required"`
+}
+
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Format ID tidak valid")
+		return
+	}
+
+	var req RefundPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "Request tidak valid: "+err.Error())
+		return
+	}
+
+	refund, err := h.svc.RefundPayment(c.Request.Context(), uint(id), req.Amount, req.Reason)
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound):
+		response.Error(c, http.StatusNotFound, "Pembayaran tidak ditemukan")
+		return
+	case errors.Is(err, service.ErrInvalidRefundAmount):
+		response.Error(c, http.StatusBadRequest, "Gagal refund pembayaran: "+err.Error())
+		return
+	case errors.Is(err, service.ErrPaymentNotRefundable):
+		response.Error(c, http.StatusConflict, "Gagal refund pembayaran: "+err.Error())
+		return
+	case err != nil:
+		response.Error(c, http.StatusInternalServerError, "Gagal refund pembayaran: "+err.Error())
+		return
+	}
+
+	response.Success(c, "Pembayaran berhasil di-refund", refund)
 }
 
 func (h *PaymentHandler) GetPaymentByOrderID(c *gin.Context) {
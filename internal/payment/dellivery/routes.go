@@ -7,5 +7,7 @@ func RegisterRoutes(router *gin.Engine, handler *PaymentHandler) {
 	{
 		payments.POST("", handler.ConfirmPayment)
 		payments.GET("/:order_id", handler.GetPaymentByOrderID)
+		payments.POST("/:id/fail", handler.FailPayment)
+		payments.POST("/:id/refund", handler.RefundPayment)
 	}
 }
@@ -1,12 +1,14 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 
 	"github.com/google/uuid"
 	"github.com/purnama/Event-Driven-Logistic/internal/payment/service"
 	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
 )
 
 type PaymentConsumer struct {
@@ -22,42 +24,80 @@ func NewPaymentConsumer(consumer *broker.Consumer, svc service.PaymentService) *
 }
 
 func (pc *PaymentConsumer) StartListening() error {
-	if err := pc.consumer.Subscribe(
+	if err := pc.consumer.SubscribeHandler(
 		"payment.order.created",
 		"order.created",
 		pc.handleOrderCreated,
+		broker.DefaultSubscribeOptions(),
 	); err != nil {
 		return err
 	}
 
-	log.Println("✅ Payment Consumer: listening for order.created")
+	if err := pc.consumer.SubscribeHandler(
+		"payment.order.cancelled",
+		"order.cancelled",
+		pc.handleOrderCancelled,
+		broker.DefaultSubscribeOptions(),
+	); err != nil {
+		return err
+	}
+
+	log.Println("✅ Payment Consumer: listening for order.created, order.cancelled")
 	return nil
 }
 
-func (pc *PaymentConsumer) handleOrderCreated(event broker.Event) error {
+func (pc *PaymentConsumer) handleOrderCreated(ctx context.Context, d *broker.Delivery) error {
+
 	var payload broker.OrderCreatedPayload
-	if err := json.Unmarshal(event.Payload, &payload); err != nil {
-		log.Printf("❌ Gagal parse OrderCreatedPayload: %v", err)
-		return err
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse OrderCreatedPayload: %v", err)
+		return broker.Permanent(err)
 	}
 
-	log.Printf("📨 Order.created diterima di Payment: OrderID=%s, Amount=%.2f",
-		payload.OrderID, payload.TotalPrice)
+	// Payload lama (float) tidak membawa currency
+	amount := payload.TotalPrice.WithDefaultCurrency()
+
+	correlation.Logf(ctx, "📨 Order.created diterima di Payment: OrderID=%s, Amount=%s",
+		payload.OrderID, amount)
 
 	orderID, err := uuid.Parse(payload.OrderID)
 	if err != nil {
-		log.Printf("❌ Format OrderID tidak valid: %s", payload.OrderID)
-		return err
+		correlation.Logf(ctx, "❌ Format OrderID tidak valid: %s", payload.OrderID)
+		return broker.Permanent(err)
 	}
 
-	payment, err := pc.svc.CreatePayment(orderID, payload.TotalPrice)
+	payment, err := pc.svc.CreatePayment(ctx, orderID, amount)
 	if err != nil {
-		log.Printf("❌ Gagal membuat payment: %v", err)
+		correlation.Logf(ctx, "❌ Gagal membuat payment: %v", err)
 		return err
 	}
 
-	log.Printf("✅ Payment PENDING dibuat: PaymentID=%d, OrderID=%s, Amount=%.2f",
-		payment.ID, payload.OrderID, payload.TotalPrice)
+	correlation.Logf(ctx, "✅ Payment PENDING dibuat: PaymentID=%d, OrderID=%s, Amount=%s",
+		payment.ID, payload.OrderID, payment.Amount)
+
+	return nil
+}
 
+func (pc *PaymentConsumer) handleOrderCancelled(ctx context.Context, d *broker.Delivery) error {
+
+	var payload broker.OrderCancelledPayload
+	if err := json.Unmarshal(d.Event.Payload, &payload); err != nil {
+		correlation.Logf(ctx, "❌ Gagal parse OrderCancelledPayload: %v", err)
+		return broker.Permanent(err)
+	}
+
+	correlation.Logf(ctx, "📨 Order.cancelled diterima di Payment: OrderID=%s, StatusSebelumnya=%s, Reason=%s",
+		payload.OrderID, payload.PreviousStatus, payload.Reason)
+
+	orderID, err := uuid.Parse(payload.OrderID)
+	if err != nil {
+		correlation.Logf(ctx, "❌ Format OrderID tidak valid: %s", payload.OrderID)
+		return broker.Permanent(err)
+	}
+
+	if err := pc.svc.HandleOrderCancelled(ctx, orderID, payload.Reason); err != nil {
+		correlation.Logf(ctx, "❌ Gagal memproses pembatalan order di Payment: %v", err)
+		return err
+	}
 	return nil
 }
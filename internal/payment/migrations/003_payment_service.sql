@@ -2,16 +2,18 @@
 -- Database: db_payment
 
 -- Create ENUM type for payment status
-CREATE TYPE payment_status AS ENUM ('WAITING', 'SUCCESS', 'FAILED');
+CREATE TYPE payment_status AS ENUM ('PENDING', 'COMPLETED', 'FAILED', 'REFUNDED', 'PARTIALLY_REFUNDED');
 
 -- Create payments table
 CREATE TABLE IF NOT EXISTS payments (
     id SERIAL PRIMARY KEY,
     order_id UUID NOT NULL UNIQUE,
     amount DECIMAL(12, 2) NOT NULL,
-    payment_status payment_status NOT NULL DEFAULT 'WAITING',
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+    payment_status payment_status NOT NULL DEFAULT 'PENDING',
     payment_method VARCHAR(50) DEFAULT 'CREDIT_CARD',
     transaction_id VARCHAR(255),
+    correlation_id VARCHAR(64),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
@@ -20,6 +22,8 @@ CREATE TABLE IF NOT EXISTS payments (
 CREATE INDEX idx_payments_order_id ON payments(order_id);
 CREATE INDEX idx_payments_status ON payments(payment_status);
 CREATE INDEX idx_payments_created_at ON payments(created_at DESC);
+-- Dipakai worker kedaluwarsa untuk mencari payment PENDING yang melewati TTL
+CREATE INDEX idx_payments_pending_created_at ON payments(created_at) WHERE payment_status = 'PENDING';
 
 -- Create trigger to update updated_at
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -34,3 +38,52 @@ CREATE TRIGGER update_payments_updated_at
     BEFORE UPDATE ON payments
     FOR EACH ROW
     EXECUTE FUNCTION update_updated_at_column();
+
+-- Create refunds table (pengembalian dana penuh atau sebagian per payment)
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES payments(id),
+    order_id UUID NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_refunds_payment_id ON refunds(payment_id);
+CREATE INDEX idx_refunds_order_id ON refunds(order_id);
+
+-- Create cancelled_orders table (order yang dibatalkan sebelum payment-nya dibuat)
+CREATE TABLE IF NOT EXISTS cancelled_orders (
+    order_id UUID PRIMARY KEY,
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create outbox table (transactional outbox untuk event payment.*)
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id SERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    body TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE,
+    failed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Dipakai relay untuk mencari pesan yang belum terkirim; pesan rusak (failed_at) tidak ikut
+CREATE INDEX idx_outbox_messages_next_attempt_at ON outbox_messages(next_attempt_at) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_messages_published_at ON outbox_messages(published_at);
+
+-- Create processed_events table (event yang sudah diproses per queue, untuk idempotency consumer)
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(64) NOT NULL,
+    consumer VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, consumer)
+);
+
+CREATE INDEX idx_processed_events_processed_at ON processed_events(processed_at);
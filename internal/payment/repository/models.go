@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
 	"gorm.io/gorm"
 )
 
@@ -15,11 +16,14 @@ const (
 	PaymentStatusCompleted PaymentStatus = "COMPLETED"
 	PaymentStatusFailed    PaymentStatus = "FAILED"
 	PaymentStatusRefunded  PaymentStatus = "REFUNDED"
+	// PaymentStatusPartiallyRefunded berarti sebagian nominal sudah dikembalikan.
+	PaymentStatusPartiallyRefunded PaymentStatus = "PARTIALLY_REFUNDED"
 )
 
 func (s PaymentStatus) IsValid() bool {
 	switch s {
-	case PaymentStatusPending, PaymentStatusCompleted, PaymentStatusFailed, PaymentStatusRefunded:
+	case PaymentStatusPending, PaymentStatusCompleted, PaymentStatusFailed, PaymentStatusRefunded,
+		PaymentStatusPartiallyRefunded:
 		return true
 	}
 	return false
@@ -27,10 +31,12 @@ func (s PaymentStatus) IsValid() bool {
 
 type Payment struct {
 	ID            uint          `gorm:"primaryKey" json:"id"`
-	OrderID       uuid.UUID     `gorm:"type:uuid;not null;index" json:"order_id"`
-	Amount        float64       `gorm:"not null;check:amount >= 0" json:"amount"`
+	OrderID       uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex" json:"order_id"`
+	Amount        money.Money   `gorm:"type:decimal(12,2);not null;check:amount >= 0" json:"amount"`
+	Currency      string        `gorm:"type:varchar(3);not null;default:IDR" json:"currency"`
 	PaymentStatus PaymentStatus `gorm:"type:varchar(20);default:PENDING;not null" json:"status"`
 	PaidAt        *time.Time    `json:"paid_at,omitempty"`
+	CorrelationID string        `gorm:"type:varchar(64)" json:"correlation_id,omitempty"`
 	CreatedAt     time.Time     `json:"created_at"`
 	UpdatedAt     time.Time     `json:"updated_at"`
 }
@@ -39,9 +45,19 @@ func (p *Payment) BeforeCreate(tx *gorm.DB) (err error) {
 	if !p.PaymentStatus.IsValid() {
 		return fmt.Errorf("invalid payment status: %s", p.PaymentStatus)
 	}
-	if p.Amount < 0 {
-		return fmt.Errorf("payment amount cannot be negative: %f", p.Amount)
+	if p.Amount.IsNegative() {
+		return fmt.Errorf("payment amount cannot be negative: %s", p.Amount)
 	}
+	if p.Currency == "" {
+		p.Currency = p.Amount.WithDefaultCurrency().Currency
+	}
+
+	return nil
+}
+
+// AfterFind mengisi currency Amount dari kolom currency.
+func (p *Payment) AfterFind(tx *gorm.DB) (err error) {
+	p.Amount.Currency = p.Currency
 
 	return nil
 }
@@ -53,3 +69,41 @@ func (p *Payment) BeforeUpdate(tx *gorm.DB) (err error) {
 
 	return nil
 }
+
+// CancelledOrder menandai order yang dibatalkan sebelum payment-nya dibuat,
+// sehingga order.created yang diproses belakangan tidak menghasilkan payment
+// yang masih bisa dibayar.
+type CancelledOrder struct {
+	OrderID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"order_id"`
+	Reason    string    `gorm:"type:text" json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Refund adalah satu pengembalian dana (penuh atau sebagian) atas payment.
+type Refund struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	PaymentID uint        `gorm:"not null;index" json:"payment_id"`
+	OrderID   uuid.UUID   `gorm:"type:uuid;not null;index" json:"order_id"`
+	Amount    money.Money `gorm:"type:decimal(12,2);not null;check:amount > 0" json:"amount"`
+	Currency  string      `gorm:"type:varchar(3);not null;default:IDR" json:"currency"`
+	Reason    string      `gorm:"type:text" json:"reason"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (r *Refund) BeforeCreate(tx *gorm.DB) (err error) {
+	if !r.Amount.IsPositive() {
+		return fmt.Errorf("refund amount must be positive: %s", r.Amount)
+	}
+	if r.Currency == "" {
+		r.Currency = r.Amount.WithDefaultCurrency().Currency
+	}
+
+	return nil
+}
+
+// AfterFind mengisi currency Amount dari kolom currency.
+func (r *Refund) AfterFind(tx *gorm.DB) (err error) {
+	r.Amount.Currency = r.Currency
+
+	return nil
+}
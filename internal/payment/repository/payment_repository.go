@@ -1,7 +1,11 @@
 package repository
 
 import (
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 
@@ -11,8 +15,25 @@ type PaymentRepository interface {
 
 	GetPaymentByOrderID(orderID string) (*Payment, error)
 	GetPaymentByID(id uint) (*Payment, error)
+	// GetPaymentByIDForUpdate mengunci baris payment sampai transaksi selesai.
+	GetPaymentByIDForUpdate(id uint) (*Payment, error)
+
+	// LockExpiredPending mengunci payment PENDING yang dibuat sebelum cutoff.
+	// Baris yang sedang dikunci replika lain dilewati (SKIP LOCKED).
+	LockExpiredPending(cutoff time.Time, limit int) ([]Payment, error)
 
 	UpdatePayment(payment *Payment) error
+
+	CreateRefund(refund *Refund) error
+	GetRefundsByPaymentID(paymentID uint) ([]Refund, error)
+
+	// MarkOrderCancelled mencatat order yang dibatalkan; marker yang sudah ada dibiarkan.
+	MarkOrderCancelled(order *CancelledOrder) error
+	GetCancelledOrder(orderID string) (*CancelledOrder, error)
+
+	// Transaction menjalankan fn dalam satu transaksi DB; event yang ditulis
+	// ke outbox ikut ter-commit bersama perubahan payment.
+	Transaction(fn func(repo PaymentRepository, outbox *broker.Outbox) error) error
 }
 
 
@@ -47,6 +68,57 @@ func (r *paymentRepository) GetPaymentByID(id uint) (*Payment, error) {
 	return &payment, nil 
 }
 
+func (r *paymentRepository) GetPaymentByIDForUpdate(id uint) (*Payment, error) {
+	var payment Payment
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *paymentRepository) LockExpiredPending(cutoff time.Time, limit int) ([]Payment, error) {
+	var payments []Payment
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("payment_status = ? AND created_at < ?", PaymentStatusPending, cutoff).
+		Order("id").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
 func (r *paymentRepository) UpdatePayment(payment *Payment) error {
 	return r.db.Save(payment).Error 
 }
+
+func (r *paymentRepository) CreateRefund(refund *Refund) error {
+	return r.db.Create(refund).Error
+}
+
+func (r *paymentRepository) GetRefundsByPaymentID(paymentID uint) ([]Refund, error) {
+	var refunds []Refund
+	err := r.db.Where("payment_id = ?", paymentID).Order("id").Find(&refunds).Error
+	if err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+func (r *paymentRepository) MarkOrderCancelled(order *CancelledOrder) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(order).Error
+}
+
+func (r *paymentRepository) GetCancelledOrder(orderID string) (*CancelledOrder, error) {
+	var order CancelledOrder
+	err := r.db.Where("order_id = ?", orderID).First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *paymentRepository) Transaction(fn func(repo PaymentRepository, outbox *broker.Outbox) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&paymentRepository{db: tx}, broker.NewOutbox(tx))
+	})
+}
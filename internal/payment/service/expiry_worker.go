@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const defaultExpiryBatchSize = 100
+
+// ExpiryWorker mem-fail payment yang masih PENDING setelah TTL secara berkala.
+// Payment dikunci dengan FOR UPDATE SKIP LOCKED sehingga beberapa replika
+// bisa menjalankan worker bersamaan tanpa mem-fail payment yang sama dua kali.
+type ExpiryWorker struct {
+	svc       PaymentService
+	ttl       time.Duration
+	interval  time.Duration
+	batchSize int
+}
+
+func NewExpiryWorker(svc PaymentService, ttl, interval time.Duration) *ExpiryWorker {
+	return &ExpiryWorker{
+		svc:       svc,
+		ttl:       ttl,
+		interval:  interval,
+		batchSize: defaultExpiryBatchSize,
+	}
+}
+
+// Run memeriksa payment kedaluwarsa sampai ctx dibatalkan. Batch yang sedang
+// berjalan diselesaikan dulu sebelum return.
+func (w *ExpiryWorker) Run(ctx context.Context) {
+	log.Printf("⌛ Payment expiry worker berjalan (ttl=%s, interval=%s)", w.ttl, w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			n, err := w.svc.ExpirePendingPayments(context.WithoutCancel(ctx), time.Now().Add(-w.ttl), w.batchSize)
+			if err != nil {
+				log.Printf("❌ Payment expiry worker gagal memproses batch: %v", err)
+				break
+			}
+			if n < w.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("⚠️ Payment expiry worker stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
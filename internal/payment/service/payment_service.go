@@ -1,24 +1,65 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/purnama/Event-Driven-Logistic/internal/payment/repository"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+	"gorm.io/gorm"
 )
 
 type PaymentService interface {
-	CreatePayment(orderID uuid.UUID, amount float64) (*repository.Payment, error)
+	CreatePayment(ctx context.Context, orderID uuid.UUID, amount money.Money) (*repository.Payment, error)
 
 	GetPaymentByOrderID(orderID string) (*repository.Payment, error)
 
-	ConfirmPayment(paymentID uint) (*repository.Payment, error)
+	// ConfirmPayment menandai payment COMPLETED dan menulis payment.success ke outbox.
+	ConfirmPayment(ctx context.Context, paymentID uint) (*repository.Payment, error)
 
-	FailPayment(paymentID uint) error
+	// FailPayment menandai payment PENDING sebagai FAILED dan menulis payment.failed ke outbox.
+	FailPayment(ctx context.Context, paymentID uint, reason string) (*repository.Payment, error)
+
+	// RefundPayment mengembalikan dana payment COMPLETED. amount nil berarti
+	// refund penuh sisa nominal yang belum dikembalikan; refund dicatat di
+	// tabel refunds dan payment.refunded ditulis ke outbox.
+	RefundPayment(ctx context.Context, paymentID uint, amount *money.Money, reason string) (*repository.Refund, error)
+
+	// HandleOrderCancelled menyelesaikan payment milik order yang dibatalkan:
+	// payment PENDING di-fail dan payment yang sudah dibayar di-refund penuh.
+	// Jika payment belum ada, order ditandai agar payment yang dibuat
+	// belakangan langsung FAILED dan tidak bisa dikonfirmasi.
+	HandleOrderCancelled(ctx context.Context, orderID uuid.UUID, reason string) error
+
+	// ExpirePendingPayments mem-fail maksimal limit payment PENDING yang dibuat
+	// sebelum cutoff dan mengembalikan jumlahnya. Aman dijalankan beberapa replika.
+	ExpirePendingPayments(ctx context.Context, cutoff time.Time, limit int) (int, error)
 }
 
+// ReasonExpired adalah alasan payment.failed dari worker kedaluwarsa.
+const ReasonExpired = "pembayaran kedaluwarsa"
+
+var (
+	ErrPaymentNotFound   = errors.New("payment tidak ditemukan")
+	ErrPaymentNotPending = errors.New("hanya payment dengan status PENDING yang bisa diproses")
+
+	// ErrPaymentNotRefundable dikembalikan jika payment belum dibayar atau sudah di-refund penuh.
+	ErrPaymentNotRefundable = errors.New("payment tidak bisa di-refund")
+
+	// ErrOrderCancelled dikembalikan saat konfirmasi payment milik order yang sudah dibatalkan.
+	ErrOrderCancelled = errors.New("order sudah dibatalkan")
+
+	// ErrInvalidRefundAmount dikembalikan jika nominal refund bukan positif,
+	// melebihi sisa nominal, atau currency-nya berbeda dengan payment.
+	ErrInvalidRefundAmount = errors.New("nominal refund tidak valid")
+)
+
 type paymentService struct {
 	repo repository.PaymentRepository
 }
@@ -27,24 +68,49 @@ func NewPaymentService(repo repository.PaymentRepository) PaymentService {
 	return &paymentService{repo: repo}
 }
 
-func (s *paymentService) CreatePayment(orderID uuid.UUID, amount float64) (*repository.Payment, error) {
-	if amount <= 0 {
+func (s *paymentService) CreatePayment(ctx context.Context, orderID uuid.UUID, amount money.Money) (*repository.Payment, error) {
+	if !amount.IsPositive() {
 		return nil, errors.New("jumlah pembayaran harus lebih dari 0")
 	}
 
+	// Satu order hanya punya satu payment; order.created yang terkirim ulang
+	// mengembalikan payment yang sudah ada
+	if existing, err := s.repo.GetPaymentByOrderID(orderID.String()); err == nil {
+		correlation.Logf(ctx, "⏭️ Payment untuk order %s sudah ada: ID=%d, Status=%s",
+			orderID, existing.ID, existing.PaymentStatus)
+		return existing, nil
+	}
+
 	payment := &repository.Payment{
 		OrderID:       orderID,
 		Amount:        amount,
+		Currency:      amount.Currency,
 		PaymentStatus: repository.PaymentStatusPending,
+		CorrelationID: correlation.CorrelationID(ctx),
 	}
 
-	if err := s.repo.CreatePayment(payment); err != nil {
-		log.Printf("❌ Gagal membuat payment: OrderID=%s, error=%v", orderID, err)
+	// order.cancelled yang diproses lebih dulu meninggalkan marker; payment
+	// tetap dibuat agar order.created berikutnya idempoten, tapi langsung FAILED
+	err := s.repo.Transaction(func(repo repository.PaymentRepository, outbox *broker.Outbox) error {
+		if err := repo.CreatePayment(payment); err != nil {
+			return err
+		}
+		cancelled, err := repo.GetCancelledOrder(orderID.String())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return failPayment(ctx, repo, outbox, payment, cancelledReason(cancelled.Reason))
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal membuat payment: OrderID=%s, error=%v", orderID, err)
 		return nil, err
 	}
 
-	log.Printf("✅ Payment dibuat: ID=%d, OrderID=%s, Amount=%.2f, Status=PENDING",
-		payment.ID, orderID, amount)
+	correlation.Logf(ctx, "✅ Payment dibuat: ID=%d, OrderID=%s, Amount=%s, Status=%s",
+		payment.ID, orderID, amount, payment.PaymentStatus)
 
 	return payment, nil
 }
@@ -62,47 +128,284 @@ func (s *paymentService) GetPaymentByOrderID(orderID string) (*repository.Paymen
 	return payment, nil
 }
 
-func (s *paymentService) ConfirmPayment(paymentID uint) (*repository.Payment, error) {
-	payment, err := s.repo.GetPaymentByID(paymentID)
+func (s *paymentService) ConfirmPayment(ctx context.Context, paymentID uint) (*repository.Payment, error) {
+	var payment *repository.Payment
+	err := s.repo.Transaction(func(repo repository.PaymentRepository, outbox *broker.Outbox) error {
+		var err error
+		payment, err = lockPending(repo, paymentID)
+		if err != nil {
+			return err
+		}
+
+		// Payment yang dibuat bersamaan dengan pembatalan order masih PENDING;
+		// worker kedaluwarsa yang akan mem-fail-nya
+		_, err = repo.GetCancelledOrder(payment.OrderID.String())
+		if err == nil {
+			return fmt.Errorf("%w: OrderID=%s", ErrOrderCancelled, payment.OrderID)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		now := time.Now()
+		payment.PaymentStatus = repository.PaymentStatusCompleted
+		payment.PaidAt = &now
+		if err := repo.UpdatePayment(payment); err != nil {
+			return err
+		}
+
+		return outbox.EnqueueEventWithContext(flowContext(ctx, payment), broker.PaymentSuccess, broker.PaymentSuccessPayload{
+			OrderID:   payment.OrderID.String(),
+			PaymentID: payment.ID,
+			Amount:    payment.Amount,
+		})
+	})
 	if err != nil {
-		return nil, errors.New("payment tidak ditemukan")
+		correlation.Logf(ctx, "❌ Gagal konfirmasi payment: ID=%d, error=%v", paymentID, err)
+		return nil, err
 	}
 
-	if payment.PaymentStatus != repository.PaymentStatusPending {
-		return nil, errors.New("hanya payment dengan status PENDING yang bisa dikonfirmasi")
-	}
+	correlation.Logf(ctx, "✅ Payment dikonfirmasi: ID=%d, OrderID=%s, Status=COMPLETED",
+		payment.ID, payment.OrderID)
 
-	now := time.Now()
-	payment.PaymentStatus = repository.PaymentStatusCompleted
-	payment.PaidAt = &now
-	if err := s.repo.UpdatePayment(payment); err != nil {
-		log.Printf("❌ Gagal konfirmasi payment: ID=%d, error=%v", paymentID, err)
+	return payment, nil
+}
+
+func (s *paymentService) FailPayment(ctx context.Context, paymentID uint, reason string) (*repository.Payment, error) {
+	var payment *repository.Payment
+	err := s.repo.Transaction(func(repo repository.PaymentRepository, outbox *broker.Outbox) error {
+		var err error
+		payment, err = lockPending(repo, paymentID)
+		if err != nil {
+			return err
+		}
+		return failPayment(ctx, repo, outbox, payment, reason)
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal mengubah payment ke FAILED: ID=%d, error=%v", paymentID, err)
 		return nil, err
 	}
 
-	log.Printf("✅ Payment dikonfirmasi: ID=%d, OrderID=%s, Status=COMPLETED",
-		payment.ID, payment.OrderID)
+	correlation.Logf(ctx, "⚠️ Payment gagal: ID=%d, OrderID=%s, Status=FAILED, Reason=%s",
+		payment.ID, payment.OrderID, reason)
 
 	return payment, nil
 }
 
-func (s *paymentService) FailPayment(paymentID uint) error {
-	payment, err := s.repo.GetPaymentByID(paymentID)
+func (s *paymentService) RefundPayment(ctx context.Context, paymentID uint, amount *money.Money, reason string) (*repository.Refund, error) {
+	var (
+		refund  *repository.Refund
+		payment *repository.Payment
+	)
+	err := s.repo.Transaction(func(repo repository.PaymentRepository, outbox *broker.Outbox) error {
+		var err error
+		payment, err = repo.GetPaymentByIDForUpdate(paymentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: ID=%d", ErrPaymentNotFound, paymentID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if payment.PaymentStatus != repository.PaymentStatusCompleted &&
+			payment.PaymentStatus != repository.PaymentStatusPartiallyRefunded {
+			return fmt.Errorf("%w: status saat ini %s", ErrPaymentNotRefundable, payment.PaymentStatus)
+		}
+
+		refunds, err := repo.GetRefundsByPaymentID(payment.ID)
+		if err != nil {
+			return err
+		}
+		refunded := money.New(0, payment.Currency)
+		for _, r := range refunds {
+			if refunded, err = refunded.Add(r.Amount); err != nil {
+				return err
+			}
+		}
+
+		remaining, err := payment.Amount.Sub(refunded)
+		if err != nil {
+			return err
+		}
+		refundAmount := remaining
+		if amount != nil {
+			refundAmount = *amount
+		}
+		if err := checkRefundAmount(refundAmount, remaining); err != nil {
+			return err
+		}
+
+		refund = &repository.Refund{
+			PaymentID: payment.ID,
+			OrderID:   payment.OrderID,
+			Amount:    refundAmount,
+			Currency:  payment.Currency,
+			Reason:    reason,
+		}
+		refund.Amount.Currency = payment.Currency
+		if err := repo.CreateRefund(refund); err != nil {
+			return err
+		}
+
+		total, err := refunded.Add(refund.Amount)
+		if err != nil {
+			return err
+		}
+		fully := total.Amount == payment.Amount.Amount
+		payment.PaymentStatus = repository.PaymentStatusPartiallyRefunded
+		if fully {
+			payment.PaymentStatus = repository.PaymentStatusRefunded
+		}
+		if err := repo.UpdatePayment(payment); err != nil {
+			return err
+		}
+
+		return outbox.EnqueueEventWithContext(flowContext(ctx, payment), broker.PaymentRefunded, broker.PaymentRefundedPayload{
+			OrderID:       payment.OrderID.String(),
+			PaymentID:     payment.ID,
+			RefundID:      refund.ID,
+			Amount:        refund.Amount,
+			TotalRefunded: total,
+			FullyRefunded: fully,
+			Reason:        reason,
+		})
+	})
+	if err != nil {
+		correlation.Logf(ctx, "❌ Gagal refund payment: ID=%d, error=%v", paymentID, err)
+		return nil, err
+	}
+
+	correlation.Logf(ctx, "💸 Payment di-refund: ID=%d, OrderID=%s, Amount=%s, Status=%s",
+		payment.ID, payment.OrderID, refund.Amount, payment.PaymentStatus)
+
+	return refund, nil
+}
+
+func (s *paymentService) HandleOrderCancelled(ctx context.Context, orderID uuid.UUID, reason string) error {
+	payment, err := s.repo.GetPaymentByOrderID(orderID.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := s.repo.MarkOrderCancelled(&repository.CancelledOrder{OrderID: orderID, Reason: reason}); err != nil {
+			return err
+		}
+		correlation.Logf(ctx, "⏭️ Belum ada payment untuk order %s, order ditandai dibatalkan", orderID)
+
+		// Payment bisa saja dibuat tepat sebelum marker tersimpan
+		payment, err = s.repo.GetPaymentByOrderID(orderID.String())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+	}
 	if err != nil {
-		return errors.New("payment tidak ditemukan")
+		return err
+	}
+
+	reason = cancelledReason(reason)
+	switch payment.PaymentStatus {
+	case repository.PaymentStatusPending:
+		_, err = s.FailPayment(ctx, payment.ID, reason)
+	case repository.PaymentStatusCompleted, repository.PaymentStatusPartiallyRefunded:
+		_, err = s.RefundPayment(ctx, payment.ID, nil, reason)
+	default:
+		correlation.Logf(ctx, "⏭️ Payment %d untuk order %s sudah %s, tidak ada yang dikembalikan",
+			payment.ID, orderID, payment.PaymentStatus)
+		return nil
+	}
+
+	// Status berubah di antara pembacaan dan penguncian, misal dikonfirmasi
+	// atau di-refund lewat API; hasil akhirnya sudah ditangani jalur lain.
+	if errors.Is(err, ErrPaymentNotPending) || errors.Is(err, ErrPaymentNotRefundable) {
+		correlation.Logf(ctx, "⚠️ Payment %d berubah status saat order dibatalkan: %v", payment.ID, err)
+		return nil
+	}
+	return err
+}
+
+func (s *paymentService) ExpirePendingPayments(ctx context.Context, cutoff time.Time, limit int) (int, error) {
+	var expired []repository.Payment
+	err := s.repo.Transaction(func(repo repository.PaymentRepository, outbox *broker.Outbox) error {
+		var err error
+		expired, err = repo.LockExpiredPending(cutoff, limit)
+		if err != nil {
+			return err
+		}
+
+		for i := range expired {
+			if err := failPayment(ctx, repo, outbox, &expired[i], ReasonExpired); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range expired {
+		log.Printf("⌛ Payment kedaluwarsa: ID=%d, OrderID=%s, Status=FAILED", p.ID, p.OrderID)
+	}
+	return len(expired), nil
+}
+
+// cancelledReason adalah alasan payment.failed/payment.refunded untuk order yang dibatalkan.
+func cancelledReason(reason string) string {
+	return "order dibatalkan: " + reason
+}
+
+// checkRefundAmount memastikan amount positif, ber-currency sama dengan
+// payment, dan tidak melebihi sisa nominal yang belum dikembalikan.
+func checkRefundAmount(amount, remaining money.Money) error {
+	if !amount.IsPositive() {
+		return fmt.Errorf("%w: %s harus lebih dari 0", ErrInvalidRefundAmount, amount)
+	}
+	left, err := remaining.Sub(amount)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRefundAmount, err)
+	}
+	if left.IsNegative() {
+		return fmt.Errorf("%w: %s melebihi sisa %s", ErrInvalidRefundAmount, amount, remaining)
+	}
+	return nil
+}
+
+// lockPending mengunci payment dan memastikan statusnya masih PENDING, sehingga
+// konfirmasi, fail manual dan worker kedaluwarsa tidak saling menimpa.
+func lockPending(repo repository.PaymentRepository, paymentID uint) (*repository.Payment, error) {
+	payment, err := repo.GetPaymentByIDForUpdate(paymentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: ID=%d", ErrPaymentNotFound, paymentID)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	if payment.PaymentStatus != repository.PaymentStatusPending {
-		return errors.New("hanya payment dengan status PENDING yang bisa di-fail")
+		return nil, fmt.Errorf("%w: status saat ini %s", ErrPaymentNotPending, payment.PaymentStatus)
 	}
+	return payment, nil
+}
+
+// failPayment mengubah payment yang sudah dikunci menjadi FAILED dan menulis
+// payment.failed ke outbox di transaksi yang sama.
+func failPayment(ctx context.Context, repo repository.PaymentRepository, outbox *broker.Outbox,
+	payment *repository.Payment, reason string) error {
+
 	payment.PaymentStatus = repository.PaymentStatusFailed
-	if err := s.repo.UpdatePayment(payment); err != nil {
-		log.Printf("❌ Gagal mengubah payment ke FAILED: ID=%d, error=%v", paymentID, err)
+	if err := repo.UpdatePayment(payment); err != nil {
 		return err
 	}
 
-	log.Printf("⚠️ Payment gagal: ID=%d, OrderID=%s, Status=FAILED",
-		payment.ID, payment.OrderID)
+	return outbox.EnqueueEventWithContext(flowContext(ctx, payment), broker.PaymentFailed, broker.PaymentFailedPayload{
+		OrderID:   payment.OrderID.String(),
+		PaymentID: payment.ID,
+		Reason:    reason,
+	})
+}
 
-	return nil
+// flowContext melanjutkan correlation ID alur order yang membuat payment:
+// event payment.* bagian dari alur order, bukan alur request/worker pemicunya.
+func flowContext(ctx context.Context, payment *repository.Payment) context.Context {
+	if payment.CorrelationID == "" {
+		return ctx
+	}
+	return correlation.WithCorrelationID(ctx, payment.CorrelationID)
 }
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
+)
+
+func TestCheckRefundAmount(t *testing.T) {
+	remaining := money.New(1500000, "IDR")
+
+	tests := []struct {
+		name    string
+		amount  money.Money
+		wantErr bool
+	}{
+		{"refund penuh", money.New(1500000, "IDR"), false},
+		{"refund sebagian", money.New(500050, "IDR"), false},
+		{"tanpa currency mengikuti payment", money.New(100, ""), false},
+		{"nol", money.New(0, "IDR"), true},
+		{"negatif", money.New(-100, "IDR"), true},
+		{"melebihi sisa", money.New(1500001, "IDR"), true},
+		{"currency berbeda", money.New(100, "USD"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRefundAmount(tt.amount, remaining)
+			if tt.wantErr && !errors.Is(err, ErrInvalidRefundAmount) {
+				t.Fatalf("err = %v, want ErrInvalidRefundAmount", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/pkg/response"
+)
+
+// RequireToken hanya meneruskan request dengan header
+// "Authorization: Bearer <token>". Token kosong menonaktifkan semua endpoint
+// admin agar service yang belum dikonfigurasi tidak terbuka.
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			response.Error(c, http.StatusForbidden, "Admin API nonaktif, set ADMIN_TOKEN untuk mengaktifkan")
+			c.Abort()
+			return
+		}
+
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			response.Error(c, http.StatusUnauthorized, "Token admin tidak valid")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
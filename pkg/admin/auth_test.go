@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(token string) *gin.Engine {
+		router := gin.New()
+		router.GET("/admin", RequireToken(token), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return router
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"token benar", "rahasia", "Bearer rahasia", http.StatusOK},
+		{"token salah", "rahasia", "Bearer salah", http.StatusUnauthorized},
+		{"tanpa header", "rahasia", "", http.StatusUnauthorized},
+		{"tanpa Bearer", "rahasia", "rahasia", http.StatusUnauthorized},
+		{"ADMIN_TOKEN kosong", "", "Bearer ", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			newRouter(tt.token).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/pkg/broker"
+	"github.com/purnama/Event-Driven-Logistic/pkg/response"
+)
+
+type DeadLetterHandler struct {
+	manager *broker.DeadLetterManager
+}
+
+func NewDeadLetterHandler(manager *broker.DeadLetterManager) *DeadLetterHandler {
+	return &DeadLetterHandler{manager: manager}
+}
+
+func (h *DeadLetterHandler) ListQueues(c *gin.Context) {
+	queues, err := h.manager.Queues()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Gagal mengambil daftar dead-letter queue: "+err.Error())
+		return
+	}
+
+	response.Success(c, "Daftar dead-letter queue", queues)
+}
+
+func (h *DeadLetterHandler) ListMessages(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	messages, err := h.manager.List(c.Param("queue"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	response.Success(c, "Pesan dead-letter ditemukan", messages)
+}
+
+func (h *DeadLetterHandler) ReplayMessage(c *gin.Context) {
+	if err := h.manager.Replay(c.Param("queue"), c.Param("message_id")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	response.Success(c, "Pesan berhasil di-replay", gin.H{"message_id": c.Param("message_id")})
+}
+
+func (h *DeadLetterHandler) ReplayAll(c *gin.Context) {
+	replayed, err := h.manager.ReplayAll(c.Param("queue"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	response.Success(c, "Semua pesan berhasil di-replay", gin.H{"replayed": replayed})
+}
+
+func (h *DeadLetterHandler) Purge(c *gin.Context) {
+	purged, err := h.manager.Purge(c.Param("queue"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	response.Success(c, "Dead-letter queue dikosongkan", gin.H{"purged": purged})
+}
+
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, broker.ErrUnknownQueue), errors.Is(err, broker.ErrDeadLetterNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	default:
+		response.Error(c, http.StatusInternalServerError, err.Error())
+	}
+}
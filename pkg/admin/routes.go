@@ -0,0 +1,15 @@
+package admin
+
+import "github.com/gin-gonic/gin"
+
+// RegisterRoutes mendaftarkan endpoint dead-letter queue di belakang RequireToken(token).
+func RegisterRoutes(router *gin.Engine, handler *DeadLetterHandler, token string) {
+	dlq := router.Group("/admin/dlq", RequireToken(token))
+	{
+		dlq.GET("", handler.ListQueues)
+		dlq.GET("/:queue/messages", handler.ListMessages)
+		dlq.POST("/:queue/replay", handler.ReplayAll)
+		dlq.POST("/:queue/messages/:message_id/replay", handler.ReplayMessage)
+		dlq.DELETE("/:queue", handler.Purge)
+	}
+}
@@ -0,0 +1,120 @@
+// Package blobstore menyimpan file biner (misal foto dan tanda tangan bukti
+// pengiriman) di balik interface Store, agar penyimpanan bisa diganti tanpa
+// mengubah service.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Nama driver yang bisa dipilih lewat BLOBSTORE_DRIVER.
+const (
+	DriverLocal = "local"
+)
+
+var (
+	// ErrNotFound dikembalikan jika blob dengan key tersebut tidak ada.
+	ErrNotFound = errors.New("blob tidak ditemukan")
+
+	// ErrInvalidKey dikembalikan untuk key kosong, absolut atau keluar dari root store.
+	ErrInvalidKey = errors.New("key blob tidak valid")
+)
+
+// Store menyimpan blob berdasarkan key relatif, misal "proofs/12/photo.jpg".
+// Put menimpa blob dengan key yang sama.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// New membuat Store berdasarkan nama driver. dir dipakai driver local.
+func New(driver, dir string) (Store, error) {
+	switch driver {
+	case DriverLocal, "":
+		return NewLocal(dir)
+	}
+	return nil, fmt.Errorf("driver blobstore tidak dikenal: %q (pilih %s)", driver, DriverLocal)
+}
+
+// Local menyimpan blob sebagai file di bawah satu direktori root.
+type Local struct {
+	root string
+}
+
+func NewLocal(dir string) (*Local, error) {
+	if dir == "" {
+		return nil, errors.New("direktori blobstore tidak boleh kosong")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("gagal membuat direktori blobstore %s: %w", dir, err)
+	}
+	return &Local{root: dir}, nil
+}
+
+// Put menulis ke file sementara lalu rename, sehingga pembaca tidak pernah
+// melihat blob yang setengah tertulis.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path mengubah key menjadi path file di bawah root dan menolak key yang
+// keluar dari root (misal "../etc/passwd").
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || strings.HasPrefix(key, "/") || clean == "/" || clean[1:] != key {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,64 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalPutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	if err := store.Put(ctx, "proofs/1/photo.jpg", strings.NewReader("foto")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Put kedua menimpa isi sebelumnya
+	if err := store.Put(ctx, "proofs/1/photo.jpg", strings.NewReader("foto baru")); err != nil {
+		t.Fatalf("Put ulang: %v", err)
+	}
+
+	r, err := store.Get(ctx, "proofs/1/photo.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "foto baru" {
+		t.Errorf("Get = %q, want %q", data, "foto baru")
+	}
+
+	if err := store.Delete(ctx, "proofs/1/photo.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "proofs/1/photo.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get setelah Delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "proofs/1/photo.jpg"); err != nil {
+		t.Errorf("Delete blob yang tidak ada seharusnya tidak error: %v", err)
+	}
+}
+
+func TestLocalRejectsInvalidKeys(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../luar.txt", "proofs/../../luar.txt", "proofs//a", "proofs/./a"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestNewUnknownDriver(t *testing.T) {
+	if _, err := New("s3", t.TempDir()); err == nil {
+		t.Error("New dengan driver tidak dikenal seharusnya error")
+	}
+}
@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/purnama/Event-Driven-Logistic/pkg/config"
@@ -26,3 +27,34 @@ func CreateChannel(conn *amqp.Connection) *amqp.Channel {
 
 	return ch
 }
+
+// declareExchanges men-declare exchange utama (topic) dan dead-letter exchange (direct).
+func declareExchanges(ch *amqp.Channel) error {
+	err := ch.ExchangeDeclare(
+		ExchangeName,
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("gagal declare exchange '%s': %w", ExchangeName, err)
+	}
+
+	err = ch.ExchangeDeclare(
+		DeadLetterExchange,
+		"direct",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("gagal declare exchange '%s': %w", DeadLetterExchange, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package broker
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var ErrConnectionClosed = errors.New("koneksi RabbitMQ sudah ditutup")
+
+const (
+	reconnectInitialBackoff = time.Second
+	reconnectMaxBackoff     = 30 * time.Second
+)
+
+// Connection adalah koneksi RabbitMQ yang dipantau lewat NotifyClose dan
+// tersambung ulang otomatis dengan backoff saat broker restart.
+// Consumer dan Publisher membuka channel dari sini sehingga ikut pulih.
+type Connection struct {
+	url string
+
+	mu     sync.RWMutex
+	conn   *amqp.Connection
+	ready  chan struct{}
+	closed bool
+	done   chan struct{}
+}
+
+// NewConnection memblok sampai koneksi pertama berhasil, lalu memantau koneksi di background.
+func NewConnection(url string) *Connection {
+	c := &Connection{
+		url:   url,
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+
+	conn := c.dial()
+	if conn == nil {
+		return c
+	}
+
+	c.setConnection(conn)
+	log.Println("RabbitMQ connected successfully")
+
+	go c.watch(conn)
+	return c
+}
+
+// Channel membuka channel baru, menunggu jika koneksi sedang tersambung ulang.
+func (c *Connection) Channel() (*amqp.Channel, error) {
+	for {
+		conn, err := c.connection()
+		if err != nil {
+			return nil, err
+		}
+
+		ch, err := conn.Channel()
+		if err == nil {
+			return ch, nil
+		}
+		if !errors.Is(err, amqp.ErrClosed) {
+			return nil, err
+		}
+
+		// Koneksi baru saja putus; tunggu watch() menyambung ulang
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil || conn.IsClosed() {
+		return nil
+	}
+	return conn.Close()
+}
+
+func (c *Connection) IsClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closed
+}
+
+func (c *Connection) connection() (*amqp.Connection, error) {
+	c.mu.RLock()
+	ready := c.ready
+	c.mu.RUnlock()
+
+	select {
+	case <-ready:
+	case <-c.done:
+		return nil, ErrConnectionClosed
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return nil, ErrConnectionClosed
+	}
+	return c.conn, nil
+}
+
+func (c *Connection) setConnection(conn *amqp.Connection) {
+	c.mu.Lock()
+	c.conn = conn
+	close(c.ready)
+	c.mu.Unlock()
+}
+
+func (c *Connection) watch(conn *amqp.Connection) {
+	for {
+		reason, ok := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+		if c.IsClosed() {
+			return
+		}
+
+		if ok {
+			log.Printf("⚠️ Koneksi RabbitMQ terputus: %v", reason)
+		} else {
+			log.Println("⚠️ Koneksi RabbitMQ terputus")
+		}
+
+		c.mu.Lock()
+		c.ready = make(chan struct{})
+		c.mu.Unlock()
+
+		conn = c.dial()
+		if conn == nil {
+			return
+		}
+
+		c.setConnection(conn)
+		log.Println("✅ RabbitMQ tersambung kembali")
+	}
+}
+
+// dial mencoba terhubung dengan exponential backoff; return nil jika Connection ditutup.
+func (c *Connection) dial() *amqp.Connection {
+	delay := reconnectInitialBackoff
+	for {
+		conn, err := amqp.Dial(c.url)
+		if err == nil {
+			return conn
+		}
+
+		log.Printf("❌ Gagal terhubung ke RabbitMQ, mencoba lagi dalam %s: %v", delay, err)
+
+		select {
+		case <-c.done:
+			return nil
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > reconnectMaxBackoff {
+			delay = reconnectMaxBackoff
+		}
+	}
+}
@@ -1,41 +1,413 @@
 package broker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-type EventHandler func(event Event) error
+const (
+	// DeadLetterExchange menampung pesan yang gagal diproses setelah semua percobaan habis.
+	DeadLetterExchange = "logistic.events.dlx"
 
-type Consumer struct {
-	channel *amqp.Channel
+	HeaderAttempts           = "x-attempts"
+	HeaderError              = "x-error"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+	HeaderCorrelationID      = "x-correlation-id"
+	HeaderCausationID        = "x-causation-id"
+)
+
+var ErrConsumerStopped = errors.New("consumer sudah dihentikan")
+
+// SubscribeOptions mengatur perilaku retry untuk satu queue.
+type SubscribeOptions struct {
+	// MaxAttempts adalah jumlah total percobaan sebelum pesan dikirim ke dead-letter queue.
+	MaxAttempts int
+	// InitialBackoff adalah jeda sebelum percobaan kedua, lalu digandakan tiap percobaan.
+	InitialBackoff time.Duration
+	// MaxBackoff adalah batas atas jeda antar percobaan.
+	MaxBackoff time.Duration
+
+	// Prefetch adalah jumlah maksimal pesan belum di-ack yang dikirim broker (channel.Qos).
+	Prefetch int
+	// Workers adalah jumlah goroutine yang memproses pesan queue ini secara paralel.
+	Workers int
+	// ShardKey menentukan worker untuk sebuah event; event dengan key sama selalu
+	// diproses berurutan oleh worker yang sama. Default: OrderIDShardKey.
+	ShardKey func(event Event) string
 }
 
-func NewConsumer(channel *amqp.Channel) (*Consumer, error) {
+func DefaultSubscribeOptions() SubscribeOptions {
+	return SubscribeOptions{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Prefetch:       10,
+		Workers:        1,
+		ShardKey:       OrderIDShardKey,
+	}
+}
 
-	err := channel.ExchangeDeclare(
-		ExchangeName,
-		"topic",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+// normalize mengisi nilai yang kosong atau tidak valid dengan nilai aman.
+func (o SubscribeOptions) normalize() SubscribeOptions {
+	if o.MaxAttempts < 1 {
+		o.MaxAttempts = 1
+	}
+	if o.Workers < 1 {
+		o.Workers = 1
+	}
+	if o.Prefetch < o.Workers {
+		o.Prefetch = o.Workers
+	}
+	if o.ShardKey == nil {
+		o.ShardKey = OrderIDShardKey
+	}
+	return o
+}
+
+// OrderIDShardKey mengambil order_id dari payload event.
+func OrderIDShardKey(event Event) string {
+	var payload struct {
+		OrderID string `json:"order_id"`
+	}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return ""
+	}
+	return payload.OrderID
+}
+
+// shardIndex memetakan key ke salah satu dari n worker secara konsisten.
+func shardIndex(key string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// Backoff mengembalikan jeda sebelum percobaan berikutnya setelah attempt gagal.
+func (o SubscribeOptions) Backoff(attempt int) time.Duration {
+	delay := o.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= o.MaxBackoff {
+			return o.MaxBackoff
+		}
+	}
+	return delay
+}
+
+type subscription struct {
+	queue      string
+	routingKey string
+	handler    Handler
+	opts       SubscribeOptions
+
+	mu          sync.Mutex
+	ch          *amqp.Channel
+	consumerTag string
+
+	// settleMu menserialisasi ack/nack/republish dari beberapa worker di channel yang sama.
+	settleMu sync.Mutex
+}
+
+// Consumer membuka satu channel per subscription dari Connection. Jika channel
+// atau koneksi putus, queue di-declare ulang dan handler di-subscribe kembali.
+type Consumer struct {
+	conn *Connection
+
+	mu            sync.RWMutex
+	subscriptions []*subscription
+	middlewares   []Middleware
+	stopping      bool
+
+	// ctx diturunkan ke setiap handler dan dibatalkan jika Shutdown melewati deadline.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewConsumer(conn *Connection) (*Consumer, error) {
+	ch, err := conn.Channel()
 	if err != nil {
-		return nil, fmt.Errorf("gagal declare exchange '%s': %w", ExchangeName, err)
+		return nil, fmt.Errorf("gagal membuka channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := declareExchanges(ch); err != nil {
+		return nil, err
 	}
 
-	return &Consumer{channel: channel}, nil
+	return newConsumer(conn), nil
 }
 
+func newConsumer(conn *Connection) *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Consumer{conn: conn, ctx: ctx, cancel: cancel}
+}
+
+// Use mendaftarkan middleware untuk subscription yang dibuat setelahnya.
+// Middleware pertama menjadi lapisan terluar.
+func (c *Consumer) Use(middlewares ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// Subscribe mendaftarkan EventHandler lama dengan opsi default.
 func (c *Consumer) Subscribe(queueName, routingKey string, handler EventHandler) error {
+	return c.SubscribeWithOptions(queueName, routingKey, handler, DefaultSubscribeOptions())
+}
+
+func (c *Consumer) SubscribeWithOptions(queueName, routingKey string, handler EventHandler, opts SubscribeOptions) error {
+	return c.SubscribeHandler(queueName, routingKey, AdaptEventHandler(handler), opts)
+}
+
+// SubscribeHandler mendaftarkan Handler yang menerima context dan metadata Delivery.
+func (c *Consumer) SubscribeHandler(queueName, routingKey string, handler Handler, opts SubscribeOptions) error {
+
+	opts = opts.normalize()
+
+	sub := &subscription{
+		queue:      queueName,
+		routingKey: routingKey,
+		handler:    c.wrap(queueName, handler),
+		opts:       opts,
+	}
+
+	c.mu.Lock()
+	if c.stopping {
+		c.mu.Unlock()
+		return ErrConsumerStopped
+	}
+	c.wg.Add(1)
+	c.mu.Unlock()
 
-	q, err := c.channel.QueueDeclare(
-		queueName,
+	msgs, err := c.open(sub)
+	if err != nil {
+		c.wg.Done()
+		return err
+	}
+
+	c.mu.Lock()
+	c.subscriptions = append(c.subscriptions, sub)
+	c.mu.Unlock()
+
+	go c.consume(sub, msgs)
+
+	return nil
+}
+
+func (c *Consumer) wrap(queueName string, handler Handler) Handler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		handler = c.middlewares[i](queueName, handler)
+	}
+	return handler
+}
+
+// Shutdown berhenti menerima pesan baru lalu menunggu handler yang sedang
+// berjalan selesai (ack/nack). Jika ctx habis lebih dulu, context handler
+// dibatalkan dan pesan yang belum selesai dikembalikan ke queue.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopping = true
+	subs := append([]*subscription(nil), c.subscriptions...)
+	c.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("✅ Consumer berhenti, semua handler selesai")
+		return nil
+	case <-ctx.Done():
+		log.Println("⚠️ Batas waktu shutdown habis, membatalkan handler yang masih berjalan")
+		c.cancel()
+		return ctx.Err()
+	}
+}
+
+func (c *Consumer) isStopping() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stopping
+}
+
+// open membuka channel baru lalu men-declare topology dan mulai consume untuk sub.
+func (c *Consumer) open(sub *subscription) (<-chan amqp.Delivery, error) {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuka channel untuk queue '%s': %w", sub.queue, err)
+	}
+
+	// Retry dan dead-letter dipublish di channel ini; mode confirm memastikan
+	// pesan asli baru di-ack setelah broker menerima salinannya
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("gagal mengaktifkan confirm untuk queue '%s': %w", sub.queue, err)
+	}
+
+	tag := sub.queue + "." + uuid.NewString()
+	msgs, err := declareAndConsume(ch, sub, tag)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	sub.mu.Lock()
+	sub.ch = ch
+	sub.consumerTag = tag
+	sub.mu.Unlock()
+
+	return msgs, nil
+}
+
+// cancel menghentikan pengiriman pesan baru; channel deliveries ditutup oleh amqp.
+func (s *subscription) cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ch == nil || s.ch.IsClosed() {
+		return
+	}
+	if err := s.ch.Cancel(s.consumerTag, false); err != nil {
+		log.Printf("⚠️ Gagal cancel consumer queue '%s': %v", s.queue, err)
+	}
+}
+
+// settle menjalankan ack/nack/republish satu per satu karena worker berbagi channel.
+func (s *subscription) settle(fn func()) {
+	s.settleMu.Lock()
+	defer s.settleMu.Unlock()
+	fn()
+}
+
+func (s *subscription) channel() *amqp.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ch
+}
+
+func (c *Consumer) consume(sub *subscription, msgs <-chan amqp.Delivery) {
+	defer c.wg.Done()
+
+	for {
+		log.Printf("👂 Consumer listening on queue '%s' for '%s'...", sub.queue, sub.routingKey)
+
+		ch := sub.channel()
+		c.dispatch(ch, sub, msgs)
+
+		if c.conn.IsClosed() || c.isStopping() {
+			// Pesan yang belum di-ack dikembalikan ke queue saat channel ditutup
+			ch.Close()
+			log.Printf("⚠️ Consumer stopped for queue '%s'", sub.queue)
+			return
+		}
+
+		log.Printf("⚠️ Channel untuk queue '%s' tertutup, subscribe ulang...", sub.queue)
+
+		msgs = c.reopen(sub)
+		if msgs == nil {
+			log.Printf("⚠️ Consumer stopped for queue '%s'", sub.queue)
+			return
+		}
+	}
+}
+
+// dispatch membagi pesan ke sub.opts.Workers worker berdasarkan ShardKey
+// sehingga urutan per key (misalnya per order) tetap terjaga. Return setelah
+// msgs ditutup dan semua worker selesai memproses pesan yang sudah diterima.
+func (c *Consumer) dispatch(ch *amqp.Channel, sub *subscription, msgs <-chan amqp.Delivery) {
+	workers := sub.opts.Workers
+	if workers == 1 {
+		for msg := range msgs {
+			c.handleMessage(ch, sub, msg)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	shards := make([]chan amqp.Delivery, workers)
+	for i := range shards {
+		shards[i] = make(chan amqp.Delivery, sub.opts.Prefetch)
+
+		wg.Add(1)
+		go func(shard <-chan amqp.Delivery) {
+			defer wg.Done()
+			for msg := range shard {
+				c.handleMessage(ch, sub, msg)
+			}
+		}(shards[i])
+	}
+
+	for msg := range msgs {
+		var event Event
+		key := msg.MessageId
+		if err := json.Unmarshal(msg.Body, &event); err == nil {
+			if k := sub.opts.ShardKey(event); k != "" {
+				key = k
+			}
+		}
+		shards[shardIndex(key, workers)] <- msg
+	}
+
+	for _, shard := range shards {
+		close(shard)
+	}
+	wg.Wait()
+}
+
+// reopen mencoba subscribe ulang dengan backoff sampai berhasil atau consumer dihentikan.
+func (c *Consumer) reopen(sub *subscription) <-chan amqp.Delivery {
+	delay := reconnectInitialBackoff
+	for {
+		msgs, err := c.open(sub)
+		if err == nil {
+			log.Printf("✅ Queue '%s' berhasil di-subscribe ulang", sub.queue)
+			return msgs
+		}
+		if c.conn.IsClosed() || c.isStopping() {
+			return nil
+		}
+
+		log.Printf("❌ Gagal subscribe ulang queue '%s', mencoba lagi dalam %s: %v", sub.queue, delay, err)
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > reconnectMaxBackoff {
+			delay = reconnectMaxBackoff
+		}
+	}
+}
+
+func declareAndConsume(ch *amqp.Channel, sub *subscription, consumerTag string) (<-chan amqp.Delivery, error) {
+	if err := declareExchanges(ch); err != nil {
+		return nil, err
+	}
+
+	q, err := ch.QueueDeclare(
+		sub.queue,
 		true,
 		false,
 		false,
@@ -43,26 +415,40 @@ func (c *Consumer) Subscribe(queueName, routingKey string, handler EventHandler)
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("gagal declare queue '%s': %w", queueName, err)
+		return nil, fmt.Errorf("gagal declare queue '%s': %w", sub.queue, err)
 	}
 
-	err = c.channel.QueueBind(
+	err = ch.QueueBind(
 		q.Name,
-		routingKey,
+		sub.routingKey,
 		ExchangeName,
 		false,
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("gagal bind queue '%s' ke routing key '%s': %w", queueName, routingKey, err)
+		return nil, fmt.Errorf("gagal bind queue '%s' ke routing key '%s': %w", sub.queue, sub.routingKey, err)
+	}
+
+	if err := declareDeadLetterQueue(ch, sub.queue); err != nil {
+		return nil, err
 	}
 
-	log.Printf("✅ Queue '%s' bound to exchange '%s' with key '%s'",
-		queueName, ExchangeName, routingKey)
+	for attempt := 1; attempt < sub.opts.MaxAttempts; attempt++ {
+		if err := declareRetryQueue(ch, sub.queue, sub.opts.Backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ch.Qos(sub.opts.Prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("gagal set prefetch untuk queue '%s': %w", sub.queue, err)
+	}
 
-	msgs, err := c.channel.Consume(
+	log.Printf("✅ Queue '%s' bound to exchange '%s' with key '%s' (max attempts=%d, prefetch=%d, workers=%d)",
+		sub.queue, ExchangeName, sub.routingKey, sub.opts.MaxAttempts, sub.opts.Prefetch, sub.opts.Workers)
+
+	msgs, err := ch.Consume(
 		q.Name,
-		"",
+		consumerTag,
 		false,
 		false,
 		false,
@@ -70,34 +456,225 @@ func (c *Consumer) Subscribe(queueName, routingKey string, handler EventHandler)
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("gagal start consuming dari queue '%s': %w", queueName, err)
+		return nil, fmt.Errorf("gagal start consuming dari queue '%s': %w", sub.queue, err)
 	}
 
-	go func() {
-		log.Printf("👂 Consumer listening on queue '%s' for '%s'...", queueName, routingKey)
+	return msgs, nil
+}
 
-		for msg := range msgs {
-			var event Event
-			if err := json.Unmarshal(msg.Body, &event); err != nil {
-				log.Printf("❌ Gagal parse event dari queue '%s': %v", queueName, err)
-				msg.Nack(false, false)
-				continue
-			}
+// Queues mengembalikan nama queue yang sudah di-subscribe consumer ini.
+func (c *Consumer) Queues() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-			log.Printf("📨 Event diterima: type=%s, queue=%s", event.Type, queueName)
+	queues := make([]string, 0, len(c.subscriptions))
+	for _, sub := range c.subscriptions {
+		queues = append(queues, sub.queue)
+	}
+	return queues
+}
 
-			if err := handler(event); err != nil {
-				log.Printf("❌ Gagal proses event '%s': %v", event.Type, err)
-				msg.Nack(false, true)
-				continue
-			}
+func (c *Consumer) handleMessage(ch *amqp.Channel, sub *subscription, msg amqp.Delivery) {
+	queueName, handler, opts := sub.queue, sub.handler, sub.opts
+	attempt := attemptsFromHeaders(msg.Headers) + 1
+
+	var event Event
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		log.Printf("❌ Gagal parse event dari queue '%s': %v", queueName, err)
+		sub.settle(func() { deadLetter(ch, queueName, msg, attempt, err) })
+		return
+	}
 
-			msg.Ack(false)
-			log.Printf("✅ Event '%s' berhasil diproses dari queue '%s'", event.Type, queueName)
+	// Event lama belum punya ID; pakai MessageId agar redelivery tetap bisa dikenali
+	if event.ID == "" {
+		event.ID = msg.MessageId
+	}
+	fillCorrelationFromMessage(&event, msg)
+
+	d := &Delivery{
+		Event:       event,
+		Queue:       queueName,
+		RoutingKey:  originalRoutingKey(msg),
+		MessageID:   msg.MessageId,
+		Redelivered: msg.Redelivered,
+		Attempt:     attempt,
+		Headers:     msg.Headers,
+	}
+
+	ctx := event.Context(c.ctx)
+	correlation.Logf(ctx, "📨 Event diterima: type=%s, id=%s, queue=%s, attempt=%d", event.Type, event.ID, queueName, attempt)
+
+	if err := handler(ctx, d); err != nil {
+		if c.ctx.Err() != nil {
+			// Dibatalkan karena shutdown: kembalikan ke queue tanpa menghabiskan jatah retry
+			correlation.Logf(ctx, "⚠️ Handler event '%s' dibatalkan karena shutdown, requeue: %v", event.Type, err)
+			sub.settle(func() { msg.Nack(false, true) })
+			return
 		}
 
-		log.Printf("⚠️ Consumer stopped for queue '%s'", queueName)
-	}()
+		if IsPermanent(err) || attempt >= opts.MaxAttempts {
+			correlation.Logf(ctx, "❌ Gagal proses event '%s' (attempt %d/%d), dikirim ke dead-letter: %v",
+				event.Type, attempt, opts.MaxAttempts, err)
+			sub.settle(func() { deadLetter(ch, queueName, msg, attempt, err) })
+			return
+		}
+
+		correlation.Logf(ctx, "⚠️ Gagal proses event '%s' (attempt %d/%d), retry dalam %s: %v",
+			event.Type, attempt, opts.MaxAttempts, opts.Backoff(attempt), err)
+		sub.settle(func() { retry(ch, queueName, msg, attempt, opts.Backoff(attempt), err) })
+		return
+	}
+
+	sub.settle(func() { msg.Ack(false) })
+	correlation.Logf(ctx, "✅ Event '%s' berhasil diproses dari queue '%s'", event.Type, queueName)
+}
+
+// fillCorrelationFromMessage melengkapi correlation/causation ID dari properti
+// dan header AMQP untuk event yang body-nya belum membawa ID tersebut.
+func fillCorrelationFromMessage(event *Event, msg amqp.Delivery) {
+	if event.CorrelationID == "" {
+		event.CorrelationID = msg.CorrelationId
+	}
+	if event.CorrelationID == "" {
+		event.CorrelationID, _ = msg.Headers[HeaderCorrelationID].(string)
+	}
+	if event.CausationID == "" {
+		event.CausationID, _ = msg.Headers[HeaderCausationID].(string)
+	}
+}
+
+// retry mengirim pesan ke retry queue ber-TTL; setelah TTL habis RabbitMQ
+// mengembalikannya ke queue asal lewat default exchange. Pesan asli baru
+// di-ack setelah republish dikonfirmasi broker.
+func retry(ch *amqp.Channel, queueName string, msg amqp.Delivery, attempt int, delay time.Duration, cause error) {
+	if err := republish(ch, "", retryQueueName(queueName, delay), msg, attempt, cause); err != nil {
+		log.Printf("❌ Gagal menjadwalkan retry untuk queue '%s', requeue: %v", queueName, err)
+		msg.Nack(false, true)
+		return
+	}
+
+	msg.Ack(false)
+}
+
+func deadLetter(ch *amqp.Channel, queueName string, msg amqp.Delivery, attempt int, cause error) {
+	if err := republish(ch, DeadLetterExchange, queueName, msg, attempt, cause); err != nil {
+		log.Printf("❌ Gagal mengirim pesan ke dead-letter queue '%s', requeue: %v", DeadLetterQueueName(queueName), err)
+		msg.Nack(false, true)
+		return
+	}
+
+	msg.Ack(false)
+}
+
+// republish mengirim salinan msg lalu menunggu confirm dari broker.
+func republish(ch *amqp.Channel, exchange, routingKey string, msg amqp.Delivery, attempt int, cause error) error {
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderAttempts] = int32(attempt)
+	headers[HeaderError] = cause.Error()
+	if _, ok := headers[HeaderOriginalRoutingKey]; !ok {
+		headers[HeaderOriginalRoutingKey] = msg.RoutingKey
+	}
+
+	// MessageId dipakai admin API untuk menunjuk pesan di dead-letter queue
+	messageID := msg.MessageId
+	if messageID == "" {
+		messageID = uuid.NewString()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishConfirmTimeout)
+	defer cancel()
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(
+		ctx,
+		exchange,
+		routingKey,
+		false,
+		false,
+		amqp.Publishing{
+			Headers:       headers,
+			ContentType:   msg.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			MessageId:     messageID,
+			CorrelationId: msg.CorrelationId,
+			Timestamp:     msg.Timestamp,
+			Type:          msg.Type,
+			Body:          msg.Body,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Tanpa confirm (nack, timeout, atau channel tertutup) pesan asli di-nack
+	// dengan requeue oleh pemanggil sehingga tidak hilang
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("tidak menerima confirm dari broker: %w", err)
+	}
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+func declareDeadLetterQueue(ch *amqp.Channel, queueName string) error {
+	dlq := DeadLetterQueueName(queueName)
+
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("gagal declare dead-letter queue '%s': %w", dlq, err)
+	}
+
+	if err := ch.QueueBind(dlq, queueName, DeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("gagal bind dead-letter queue '%s': %w", dlq, err)
+	}
 
 	return nil
 }
+
+func declareRetryQueue(ch *amqp.Channel, queueName string, delay time.Duration) error {
+	name := retryQueueName(queueName, delay)
+
+	_, err := ch.QueueDeclare(
+		name,
+		true,
+		false,
+		false,
+		false,
+		amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queueName,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("gagal declare retry queue '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+// DeadLetterQueueName mengembalikan nama dead-letter queue milik queueName.
+func DeadLetterQueueName(queueName string) string {
+	return queueName + ".dlq"
+}
+
+func retryQueueName(queueName string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%dms", queueName, delay.Milliseconds())
+}
+
+func attemptsFromHeaders(headers amqp.Table) int {
+	switch v := headers[HeaderAttempts].(type) {
+	case int:
+		return v
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,144 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeOptionsBackoff(t *testing.T) {
+	opts := SubscribeOptions{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, opts.Backoff(1))
+	assert.Equal(t, 2*time.Second, opts.Backoff(2))
+	assert.Equal(t, 4*time.Second, opts.Backoff(3))
+	assert.Equal(t, 5*time.Second, opts.Backoff(4))
+}
+
+func TestAttemptsFromHeaders(t *testing.T) {
+	assert.Equal(t, 0, attemptsFromHeaders(nil))
+	assert.Equal(t, 3, attemptsFromHeaders(amqp.Table{HeaderAttempts: int32(3)}))
+	assert.Equal(t, 2, attemptsFromHeaders(amqp.Table{HeaderAttempts: int64(2)}))
+}
+
+func TestIsPermanent(t *testing.T) {
+	base := errors.New("payload rusak")
+
+	assert.True(t, IsPermanent(Permanent(base)))
+	assert.True(t, IsPermanent(fmt.Errorf("wrap: %w", Permanent(base))))
+	assert.False(t, IsPermanent(base))
+	assert.Nil(t, Permanent(nil))
+}
+
+func TestConsumerMiddlewareOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(queue string, next Handler) Handler {
+			return func(ctx context.Context, d *Delivery) error {
+				calls = append(calls, name+":"+queue)
+				return next(ctx, d)
+			}
+		}
+	}
+
+	c := newConsumer(nil)
+	c.Use(trace("outer"), trace("inner"))
+
+	handler := c.wrap("payment.order.created", AdaptEventHandler(func(event Event) error {
+		calls = append(calls, "handler:"+string(event.Type))
+		return nil
+	}))
+
+	assert.NoError(t, handler(context.Background(), &Delivery{Event: Event{Type: OrderCreated}}))
+	assert.Equal(t, []string{
+		"outer:payment.order.created",
+		"inner:payment.order.created",
+		"handler:order.created",
+	}, calls)
+}
+
+func TestConsumerShutdownWithoutSubscriptions(t *testing.T) {
+	c := newConsumer(nil)
+
+	assert.NoError(t, c.Shutdown(context.Background()))
+	assert.ErrorIs(t, c.SubscribeHandler("q", "k", nil, DefaultSubscribeOptions()), ErrConsumerStopped)
+}
+
+func TestNewEventAssignsUniqueID(t *testing.T) {
+	first, err := NewEvent(OrderCreated, map[string]string{"order_id": "a"})
+	assert.NoError(t, err)
+	second, err := NewEvent(OrderCreated, map[string]string{"order_id": "a"})
+	assert.NoError(t, err)
+
+	assert.NotEmpty(t, first.ID)
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestEventCorrelation(t *testing.T) {
+	root, err := NewEvent(OrderCreated, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, root.ID, root.CorrelationID)
+	assert.Empty(t, root.CausationID)
+
+	ctx := root.Context(context.Background())
+	child, err := NewEventWithContext(ctx, StockReserved, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, root.CorrelationID, child.CorrelationID)
+	assert.Equal(t, root.ID, child.CausationID)
+
+	fromRequest, err := NewEventWithContext(correlation.WithCorrelationID(context.Background(), "req-1"), OrderCreated, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "req-1", fromRequest.CorrelationID)
+}
+
+func TestFillCorrelationFromMessage(t *testing.T) {
+	event := Event{}
+	fillCorrelationFromMessage(&event, amqp.Delivery{
+		Headers: amqp.Table{HeaderCorrelationID: "req-1", HeaderCausationID: "evt-1"},
+	})
+
+	assert.Equal(t, "req-1", event.CorrelationID)
+	assert.Equal(t, "evt-1", event.CausationID)
+}
+
+func TestSubscribeOptionsNormalize(t *testing.T) {
+	opts := SubscribeOptions{Workers: 4, Prefetch: 2}.normalize()
+
+	assert.Equal(t, 1, opts.MaxAttempts)
+	assert.Equal(t, 4, opts.Workers)
+	assert.Equal(t, 4, opts.Prefetch)
+	assert.NotNil(t, opts.ShardKey)
+
+	assert.Equal(t, 1, SubscribeOptions{}.normalize().Workers)
+}
+
+func TestOrderIDShardKey(t *testing.T) {
+	event, err := NewEvent(OrderCreated, OrderCreatedPayload{OrderID: "order-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "order-1", OrderIDShardKey(*event))
+
+	assert.Empty(t, OrderIDShardKey(Event{Payload: []byte(`"bukan objek"`)}))
+}
+
+func TestShardIndex(t *testing.T) {
+	assert.Equal(t, 0, shardIndex("order-1", 1))
+
+	first := shardIndex("order-1", 8)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, shardIndex("order-1", 8))
+	}
+
+	used := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		idx := shardIndex(fmt.Sprintf("order-%d", i), 8)
+		assert.True(t, idx >= 0 && idx < 8)
+		used[idx] = true
+	}
+	assert.Greater(t, len(used), 1)
+}
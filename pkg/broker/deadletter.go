@@ -0,0 +1,306 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	ErrUnknownQueue = errors.New("queue tidak dikenal")
+
+	ErrDeadLetterNotFound = errors.New("pesan tidak ditemukan di dead-letter queue")
+)
+
+// DeadLetterMessage adalah ringkasan pesan yang tersimpan di dead-letter queue.
+type DeadLetterMessage struct {
+	MessageID  string          `json:"message_id"`
+	Queue      string          `json:"queue"`
+	RoutingKey string          `json:"routing_key"`
+	EventType  EventType       `json:"event_type"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+	Attempts   int             `json:"attempts"`
+	Timestamp  time.Time       `json:"timestamp"`
+}
+
+type DeadLetterQueueInfo struct {
+	Queue           string `json:"queue"`
+	DeadLetterQueue string `json:"dead_letter_queue"`
+	Messages        int    `json:"messages"`
+}
+
+// DeadLetterManager membaca, me-replay, dan mengosongkan dead-letter queue
+// milik queue yang di-subscribe oleh Consumer.
+type DeadLetterManager struct {
+	consumer  *Consumer
+	publisher *Publisher
+	mu        sync.Mutex
+	ch        *amqp.Channel
+}
+
+func NewDeadLetterManager(consumer *Consumer, publisher *Publisher) *DeadLetterManager {
+	return &DeadLetterManager{
+		consumer:  consumer,
+		publisher: publisher,
+	}
+}
+
+func (m *DeadLetterManager) Queues() ([]DeadLetterQueueInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var infos []DeadLetterQueueInfo
+	for _, queue := range m.consumer.Queues() {
+		count, err := m.messageCount(queue)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, DeadLetterQueueInfo{
+			Queue:           queue,
+			DeadLetterQueue: DeadLetterQueueName(queue),
+			Messages:        count,
+		})
+	}
+
+	return infos, nil
+}
+
+// List mengintip maksimal limit pesan tanpa menghapusnya dari dead-letter queue.
+func (m *DeadLetterManager) List(queue string, limit int) ([]DeadLetterMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries, err := m.fetch(queue, limit)
+	defer requeue(deliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]DeadLetterMessage, 0, len(deliveries))
+	for _, d := range deliveries {
+		messages = append(messages, toDeadLetterMessage(queue, d))
+	}
+
+	return messages, nil
+}
+
+// Replay mempublish ulang satu pesan ke exchange utama lalu menghapusnya dari dead-letter queue.
+func (m *DeadLetterManager) Replay(queue, messageID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count, err := m.messageCount(queue)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := m.fetch(queue, count)
+	if err != nil {
+		requeue(deliveries)
+		return err
+	}
+
+	for i, d := range deliveries {
+		if d.MessageId != messageID {
+			continue
+		}
+
+		if err := m.replay(queue, d); err != nil {
+			requeue(deliveries)
+			return err
+		}
+
+		requeue(append(deliveries[:i:i], deliveries[i+1:]...))
+		return nil
+	}
+
+	requeue(deliveries)
+	return fmt.Errorf("%w: %s", ErrDeadLetterNotFound, messageID)
+}
+
+// ReplayAll mempublish ulang semua pesan yang ada di dead-letter queue saat dipanggil.
+func (m *DeadLetterManager) ReplayAll(queue string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Dibatasi jumlah awal agar pesan yang gagal lagi tidak di-replay berulang
+	count, err := m.messageCount(queue)
+	if err != nil {
+		return 0, err
+	}
+
+	ch, err := m.channel()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for replayed < count {
+		d, ok, err := ch.Get(DeadLetterQueueName(queue), false)
+		if err != nil {
+			return replayed, fmt.Errorf("gagal membaca dead-letter queue '%s': %w", DeadLetterQueueName(queue), err)
+		}
+		if !ok {
+			break
+		}
+
+		if err := m.replay(queue, d); err != nil {
+			d.Nack(false, true)
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+func (m *DeadLetterManager) Purge(queue string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.isKnownQueue(queue) {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+
+	ch, err := m.channel()
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := ch.QueuePurge(DeadLetterQueueName(queue), false)
+	if err != nil {
+		return 0, fmt.Errorf("gagal purge dead-letter queue '%s': %w", DeadLetterQueueName(queue), err)
+	}
+
+	log.Printf("🧹 Dead-letter queue '%s' dikosongkan: %d pesan", DeadLetterQueueName(queue), purged)
+	return purged, nil
+}
+
+func (m *DeadLetterManager) replay(queue string, d amqp.Delivery) error {
+	routingKey := originalRoutingKey(d)
+	if err := m.publisher.Republish(routingKey, d.MessageId, d.Body); err != nil {
+		return err
+	}
+
+	if err := d.Ack(false); err != nil {
+		return fmt.Errorf("gagal ack pesan '%s' di dead-letter queue: %w", d.MessageId, err)
+	}
+
+	log.Printf("🔁 Pesan dead-letter di-replay: queue=%s, id=%s, routing_key=%s", queue, d.MessageId, routingKey)
+	return nil
+}
+
+func (m *DeadLetterManager) fetch(queue string, limit int) ([]amqp.Delivery, error) {
+	if !m.isKnownQueue(queue) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+
+	ch, err := m.channel()
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []amqp.Delivery
+	for len(deliveries) < limit {
+		d, ok, err := ch.Get(DeadLetterQueueName(queue), false)
+		if err != nil {
+			return deliveries, fmt.Errorf("gagal membaca dead-letter queue '%s': %w", DeadLetterQueueName(queue), err)
+		}
+		if !ok {
+			break
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+func (m *DeadLetterManager) messageCount(queue string) (int, error) {
+	if !m.isKnownQueue(queue) {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+
+	ch, err := m.channel()
+	if err != nil {
+		return 0, err
+	}
+
+	q, err := ch.QueueDeclarePassive(DeadLetterQueueName(queue), true, false, false, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("gagal inspeksi dead-letter queue '%s': %w", DeadLetterQueueName(queue), err)
+	}
+
+	return q.Messages, nil
+}
+
+// channel membuka ulang channel admin jika tertutup (misal setelah reconnect
+// atau error 404 dari QueueDeclarePassive).
+func (m *DeadLetterManager) channel() (*amqp.Channel, error) {
+	if m.ch != nil && !m.ch.IsClosed() {
+		return m.ch, nil
+	}
+
+	ch, err := m.consumer.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuka channel admin: %w", err)
+	}
+
+	m.ch = ch
+	return ch, nil
+}
+
+func (m *DeadLetterManager) isKnownQueue(queue string) bool {
+	for _, q := range m.consumer.Queues() {
+		if q == queue {
+			return true
+		}
+	}
+	return false
+}
+
+func requeue(deliveries []amqp.Delivery) {
+	for _, d := range deliveries {
+		d.Nack(false, true)
+	}
+}
+
+func originalRoutingKey(d amqp.Delivery) string {
+	if key, ok := d.Headers[HeaderOriginalRoutingKey].(string); ok && key != "" {
+		return key
+	}
+	return d.RoutingKey
+}
+
+func toDeadLetterMessage(queue string, d amqp.Delivery) DeadLetterMessage {
+	msg := DeadLetterMessage{
+		MessageID:  d.MessageId,
+		Queue:      queue,
+		RoutingKey: originalRoutingKey(d),
+		Attempts:   attemptsFromHeaders(d.Headers),
+		Timestamp:  d.Timestamp,
+	}
+
+	if reason, ok := d.Headers[HeaderError].(string); ok {
+		msg.Reason = reason
+	}
+
+	var event Event
+	if err := json.Unmarshal(d.Body, &event); err == nil {
+		msg.EventType = event.Type
+		msg.Payload = event.Payload
+	} else if json.Valid(d.Body) {
+		msg.Payload = json.RawMessage(d.Body)
+	} else {
+		raw, _ := json.Marshal(string(d.Body))
+		msg.Payload = json.RawMessage(raw)
+	}
+
+	return msg
+}
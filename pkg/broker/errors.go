@@ -0,0 +1,30 @@
+package broker
+
+import "errors"
+
+// PermanentError menandai error yang tidak akan berhasil walau dicoba ulang
+// (misal payload rusak). Consumer langsung mengirim pesan ke dead-letter queue.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent membungkus err sebagai PermanentError.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
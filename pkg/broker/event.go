@@ -1,8 +1,13 @@
 package broker
 
 import (
+	"context"
 	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/purnama/Event-Driven-Logistic/pkg/money"
 )
 
 const ExchangeName = "logistic.events"
@@ -18,6 +23,8 @@ const (
 
 	PaymentFailed EventType = "payment.failed"
 
+	PaymentRefunded EventType = "payment.refunded"
+
 	StockReserved EventType = "stock.reserved"
 
 	StockFailed EventType = "stock.failed"
@@ -25,26 +32,72 @@ const (
 	ShipmentCreated EventType = "shipment.created"
 
 	ShipmentStatusUpdated EventType = "shipment.status_updated"
+
+	ShipmentLocationUpdated EventType = "shipment.location_updated"
+
+	ShipmentETAChanged EventType = "shipment.eta_changed"
 )
 
 type Event struct {
-	Type      EventType       `json:"type"`
-	Timestamp time.Time       `json:"timestamp"`
-	Payload   json.RawMessage `json:"payload"`
+	// ID unik per event, dipakai consumer untuk mendeteksi redelivery.
+	ID string `json:"id"`
+	// CorrelationID sama untuk semua event dalam satu alur, mulai dari HTTP request.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// CausationID adalah ID event yang memicu event ini (kosong jika dari HTTP request).
+	CausationID string          `json:"causation_id,omitempty"`
+	Type        EventType       `json:"type"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+// Context mengembalikan ctx berisi correlation ID event ini, dengan event ini
+// sebagai causation untuk event yang dipublish selama event ini diproses.
+func (e Event) Context(parent context.Context) context.Context {
+	ctx := parent
+	if e.CorrelationID != "" {
+		ctx = correlation.WithCorrelationID(ctx, e.CorrelationID)
+	}
+	if e.ID != "" {
+		ctx = correlation.WithCausationID(ctx, e.ID)
+	}
+	return ctx
 }
 
 type OrderCreatedPayload struct {
-	OrderID    string  `json:"order_id"`
-	CustomerID string  `json:"customer_id"`
-	ItemName   string  `json:"item_name"`
-	Quantity   int     `json:"quantity"`
-	TotalPrice float64 `json:"total_price"`
+	OrderID    string             `json:"order_id"`
+	CustomerID string             `json:"customer_id"`
+	Items      []OrderItemPayload `json:"items"`
+	// TotalPrice dihitung order service dari harga katalog, bukan dari client.
+	// Payload lama berisi angka float tanpa currency (lihat money.Money.UnmarshalJSON).
+	TotalPrice money.Money `json:"total_price"`
+	// DeliveryAddress kosong untuk order yang dibuat sebelum alamat pengiriman wajib.
+	DeliveryAddress *DeliveryAddressPayload `json:"delivery_address,omitempty"`
+}
+
+// DeliveryAddressPayload adalah tujuan pengiriman order.
+type DeliveryAddressPayload struct {
+	Address string  `json:"address"`
+	Lat     float64 `json:"lat"`
+	Long    float64 `json:"long"`
+}
+
+type OrderItemPayload struct {
+	ItemName  string      `json:"item_name"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unit_price"`
+}
+
+type OrderCancelledPayload struct {
+	OrderID        string `json:"order_id"`
+	CustomerID     string `json:"customer_id"`
+	PreviousStatus string `json:"previous_status"`
+	Reason         string `json:"reason"`
 }
 
 type PaymentSuccessPayload struct {
-	OrderID   string  `json:"order_id"`
-	PaymentID uint    `json:"payment_id"`
-	Amount    float64 `json:"amount"`
+	OrderID   string      `json:"order_id"`
+	PaymentID uint        `json:"payment_id"`
+	Amount    money.Money `json:"amount"`
 }
 
 type PaymentFailedPayload struct {
@@ -53,29 +106,110 @@ type PaymentFailedPayload struct {
 	Reason    string `json:"reason"`
 }
 
+type PaymentRefundedPayload struct {
+	OrderID   string      `json:"order_id"`
+	PaymentID uint        `json:"payment_id"`
+	RefundID  uint        `json:"refund_id"`
+	Amount    money.Money `json:"amount"`
+	// TotalRefunded adalah akumulasi semua refund payment ini, termasuk refund ini.
+	TotalRefunded money.Money `json:"total_refunded"`
+	FullyRefunded bool        `json:"fully_refunded"`
+	Reason        string      `json:"reason"`
+}
+
 type StockReservedPayload struct {
-	OrderID       string `json:"order_id"`
-	ProductID     uint   `json:"product_id"`
-	Quantity      int    `json:"quantity"`
-	ReservationID uint   `json:"reservation_id"`
+	OrderID string                `json:"order_id"`
+	Items   []ReservedItemPayload `json:"items"`
+}
+
+type ReservedItemPayload struct {
+	ProductID     uint `json:"product_id"`
+	Quantity      int  `json:"quantity"`
+	ReservationID uint `json:"reservation_id"`
 }
 
 type StockFailedPayload struct {
-	OrderID  string `json:"order_id"`
-	ItemName string `json:"item_name"`
-	Reason   string `json:"reason"`
+	OrderID string `json:"order_id"`
+	// Items berisi baris yang gagal direservasi; kosong jika gagal bukan karena baris tertentu.
+	Items  []FailedItemPayload `json:"items,omitempty"`
+	Reason string              `json:"reason"`
+}
+
+type FailedItemPayload struct {
+	ItemName  string `json:"item_name"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+	Reason    string `json:"reason"`
+}
+
+type ShipmentCreatedPayload struct {
+	ShipmentID uint   `json:"shipment_id"`
+	OrderID    string `json:"order_id"`
+	// CourierID kosong jika shipment masih PENDING_ASSIGNMENT.
+	CourierID   *uint  `json:"courier_id,omitempty"`
+	CourierName string `json:"courier_name"`
+	Status      string `json:"status"`
+}
+
+type ShipmentStatusUpdatedPayload struct {
+	ShipmentID uint   `json:"shipment_id"`
+	OrderID    string `json:"order_id"`
+	CourierID  *uint  `json:"courier_id,omitempty"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	// Automatic bernilai true jika transisi dipicu geofence dari lokasi kurir,
+	// bukan dari PATCH /shipments/:id/status atau assignment kurir.
+	Automatic bool `json:"automatic"`
+	// ProofID adalah ID bukti pengiriman (GET /shipments/:id/proof), hanya untuk DELIVERED.
+	ProofID *uint `json:"proof_id,omitempty"`
+}
+
+// ShipmentLocationUpdatedPayload adalah posisi terbaru kurir. Dikirim paling
+// sering sekali per interval throttling per shipment, bukan untuk setiap fix GPS.
+type ShipmentLocationUpdatedPayload struct {
+	ShipmentID uint      `json:"shipment_id"`
+	OrderID    string    `json:"order_id"`
+	Lat        float64   `json:"lat"`
+	Long       float64   `json:"long"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// ShipmentETAChangedPayload dikirim saat perkiraan waktu tiba bergeser
+// melewati ambang batas dari ETA yang terakhir dikirim.
+type ShipmentETAChangedPayload struct {
+	ShipmentID         uint      `json:"shipment_id"`
+	OrderID            string    `json:"order_id"`
+	EstimatedArrivalAt time.Time `json:"estimated_arrival_at"`
+	// PreviousArrivalAt kosong untuk ETA pertama shipment.
+	PreviousArrivalAt   *time.Time `json:"previous_arrival_at,omitempty"`
+	RemainingDistanceKm float64    `json:"remaining_distance_km"`
+	SpeedKmh            float64    `json:"speed_kmh"`
 }
 
 func NewEvent(eventType EventType, payload interface{}) (*Event, error) {
+	return NewEventWithContext(context.Background(), eventType, payload)
+}
+
+// NewEventWithContext membuat event yang mewarisi correlation ID dan causation
+// ID dari ctx. Tanpa correlation ID di ctx, event menjadi awal alur baru.
+func NewEventWithContext(ctx context.Context, eventType EventType, payload interface{}) (*Event, error) {
 
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Event{
-		Type:      eventType,
-		Timestamp: time.Now(),
-		Payload:   json.RawMessage(payloadBytes),
-	}, nil
+	event := &Event{
+		ID:            uuid.NewString(),
+		CorrelationID: correlation.CorrelationID(ctx),
+		CausationID:   correlation.CausationID(ctx),
+		Type:          eventType,
+		Timestamp:     time.Now(),
+		Payload:       json.RawMessage(payloadBytes),
+	}
+	if event.CorrelationID == "" {
+		event.CorrelationID = event.ID
+	}
+
+	return event, nil
 }
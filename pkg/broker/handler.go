@@ -0,0 +1,40 @@
+package broker
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Delivery adalah event yang diterima consumer beserta metadata pengirimannya.
+type Delivery struct {
+	Event Event
+
+	Queue string
+	// RoutingKey adalah routing key asal, juga untuk pesan dari retry queue.
+	RoutingKey string
+	MessageID  string
+	// Redelivered bernilai true jika RabbitMQ mengirim ulang pesan yang belum di-ack.
+	Redelivered bool
+	// Attempt dimulai dari 1 dan bertambah setiap pesan dijadwalkan ulang lewat retry queue.
+	Attempt int
+	Headers amqp.Table
+}
+
+// Handler memproses satu Delivery. ctx membawa correlation ID event dan
+// dibatalkan saat consumer dimatikan sebelum handler selesai.
+type Handler func(ctx context.Context, d *Delivery) error
+
+// EventHandler adalah signature handler lama yang hanya menerima Event.
+type EventHandler func(event Event) error
+
+// AdaptEventHandler membungkus EventHandler agar bisa dipakai sebagai Handler.
+func AdaptEventHandler(handler EventHandler) Handler {
+	return func(ctx context.Context, d *Delivery) error {
+		return handler(d.Event)
+	}
+}
+
+// Middleware membungkus handler milik queue tertentu, misalnya untuk
+// melewati event yang sudah pernah diproses.
+type Middleware func(queue string, next Handler) Handler
@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProcessedEvent mencatat event yang sudah berhasil diproses oleh satu queue.
+type ProcessedEvent struct {
+	EventID     string    `gorm:"primaryKey;type:varchar(64)" json:"event_id"`
+	Consumer    string    `gorm:"primaryKey;type:varchar(100)" json:"consumer"`
+	EventType   EventType `gorm:"type:varchar(50);not null" json:"event_type"`
+	ProcessedAt time.Time `gorm:"not null;index" json:"processed_at"`
+}
+
+func (ProcessedEvent) TableName() string {
+	return "processed_events"
+}
+
+// ProcessedEventStore menyimpan ID event yang sudah diproses per consumer
+// sehingga redelivery dari RabbitMQ tidak diproses dua kali.
+type ProcessedEventStore struct {
+	db *gorm.DB
+}
+
+func NewProcessedEventStore(db *gorm.DB) *ProcessedEventStore {
+	return &ProcessedEventStore{db: db}
+}
+
+// Middleware melewati event yang sudah tercatat untuk queue yang sama.
+//
+// Baris processed_events baru disisipkan setelah handler berhasil, sehingga
+// tidak ada transaksi atau koneksi DB yang tertahan selama handler berjalan.
+// Jika handler gagal, event tidak tercatat dan diproses lagi saat retry.
+// Redelivery yang berjalan bersamaan bisa sama-sama memanggil handler, karena
+// itu handler tetap harus idempoten.
+func (s *ProcessedEventStore) Middleware() Middleware {
+	return func(queue string, next Handler) Handler {
+		return func(ctx context.Context, d *Delivery) error {
+			event := d.Event
+			if event.ID == "" {
+				return next(ctx, d)
+			}
+
+			db := s.db.WithContext(ctx)
+
+			var count int64
+			err := db.Model(&ProcessedEvent{}).
+				Where("event_id = ? AND consumer = ?", event.ID, queue).
+				Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				correlation.Logf(ctx, "⏭️ Event '%s' (id=%s) sudah pernah diproses oleh '%s', dilewati",
+					event.Type, event.ID, queue)
+				return nil
+			}
+
+			if err := next(ctx, d); err != nil {
+				return err
+			}
+
+			record := &ProcessedEvent{
+				EventID:     event.ID,
+				Consumer:    queue,
+				EventType:   event.Type,
+				ProcessedAt: time.Now(),
+			}
+			return db.Clauses(clause.OnConflict{DoNothing: true}).Create(record).Error
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxMessage adalah event yang menunggu dipublish ke RabbitMQ.
+// Ditulis di transaksi yang sama dengan perubahan data bisnis, lalu
+// dikirim oleh OutboxRelay sehingga event tidak hilang saat broker down.
+// FailedAt diisi jika body tidak bisa dibaca; baris itu tidak dicoba lagi
+// dan perlu diperiksa manual.
+type OutboxMessage struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	EventType     EventType  `gorm:"type:varchar(50);not null" json:"event_type"`
+	Body          string     `gorm:"type:text;not null" json:"body"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+	PublishedAt   *time.Time `gorm:"index" json:"published_at,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
+
+// Outbox menulis event ke tabel outbox memakai koneksi/transaksi GORM yang diberikan.
+type Outbox struct {
+	db *gorm.DB
+}
+
+func NewOutbox(db *gorm.DB) *Outbox {
+	return &Outbox{db: db}
+}
+
+func (o *Outbox) Enqueue(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("gagal serialize event: %w", err)
+	}
+
+	msg := &OutboxMessage{
+		EventType:     event.Type,
+		Body:          string(body),
+		NextAttemptAt: time.Now(),
+	}
+	if err := o.db.Create(msg).Error; err != nil {
+		return fmt.Errorf("gagal menyimpan event '%s' ke outbox: %w", event.Type, err)
+	}
+
+	return nil
+}
+
+func (o *Outbox) EnqueueEvent(eventType EventType, payload interface{}) error {
+	return o.EnqueueEventWithContext(context.Background(), eventType, payload)
+}
+
+// EnqueueEventWithContext menulis event yang membawa correlation ID dari ctx.
+func (o *Outbox) EnqueueEventWithContext(ctx context.Context, eventType EventType, payload interface{}) error {
+	event, err := NewEventWithContext(ctx, eventType, payload)
+	if err != nil {
+		return fmt.Errorf("gagal membuat event: %w", err)
+	}
+
+	return o.Enqueue(event)
+}
+
+const (
+	defaultRelayInterval   = 2 * time.Second
+	defaultRelayBatchSize  = 50
+	defaultRelayMaxBackoff = time.Minute
+)
+
+// OutboxRelay membaca outbox_messages yang belum terkirim dan mempublishnya
+// lewat Publisher. Baris dikunci dengan FOR UPDATE SKIP LOCKED sehingga
+// beberapa replika service bisa menjalankan relay bersamaan.
+type OutboxRelay struct {
+	db         *gorm.DB
+	publisher  *Publisher
+	interval   time.Duration
+	batchSize  int
+	maxBackoff time.Duration
+}
+
+func NewOutboxRelay(db *gorm.DB, publisher *Publisher) *OutboxRelay {
+	return &OutboxRelay{
+		db:         db,
+		publisher:  publisher,
+		interval:   defaultRelayInterval,
+		batchSize:  defaultRelayBatchSize,
+		maxBackoff: defaultRelayMaxBackoff,
+	}
+}
+
+// Start menjalankan relay di goroutine terpisah sampai ctx dibatalkan.
+func (r *OutboxRelay) Start(ctx context.Context) {
+	go r.Run(ctx)
+}
+
+// Run memproses outbox sampai ctx dibatalkan. Batch yang sedang berjalan
+// diselesaikan dulu sebelum return.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	log.Printf("📮 Outbox relay berjalan (interval=%s, batch=%d)", r.interval, r.batchSize)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			n, err := r.relayBatch()
+			if err != nil {
+				log.Printf("❌ Outbox relay gagal memproses batch: %v", err)
+				break
+			}
+			if n < r.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("⚠️ Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *OutboxRelay) relayBatch() (int, error) {
+	var processed int
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var messages []OutboxMessage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("id").
+			Limit(r.batchSize).
+			Find(&messages).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range messages {
+			published, err := r.relay(tx, &messages[i])
+			if err != nil {
+				return err
+			}
+			if !published {
+				// Berhenti di sini agar urutan event tetap terjaga
+				return nil
+			}
+			processed++
+		}
+
+		return nil
+	})
+
+	return processed, err
+}
+
+func (r *OutboxRelay) relay(tx *gorm.DB, msg *OutboxMessage) (bool, error) {
+	var event Event
+	if err := json.Unmarshal([]byte(msg.Body), &event); err != nil {
+		// Body rusak tidak akan pernah bisa dipublish; tandai mati dan lanjutkan
+		// batch agar event lain tidak ikut tertahan
+		log.Printf("❌ Outbox message %d (%s) rusak, tidak akan dicoba lagi: %v", msg.ID, msg.EventType, err)
+
+		now := time.Now()
+		return true, tx.Model(msg).Updates(map[string]interface{}{
+			"last_error": fmt.Sprintf("body tidak valid: %v", err),
+			"failed_at":  &now,
+		}).Error
+	}
+
+	if pubErr := r.publisher.Publish(&event); pubErr != nil {
+		attempts := msg.Attempts + 1
+		delay := outboxBackoff(attempts, r.maxBackoff)
+
+		log.Printf("⚠️ Outbox message %d (%s) gagal dipublish, percobaan ke-%d, retry dalam %s: %v",
+			msg.ID, msg.EventType, attempts, delay, pubErr)
+
+		return false, tx.Model(msg).Updates(map[string]interface{}{
+			"attempts":        attempts,
+			"last_error":      pubErr.Error(),
+			"next_attempt_at": time.Now().Add(delay),
+		}).Error
+	}
+
+	now := time.Now()
+	return true, tx.Model(msg).Updates(map[string]interface{}{
+		"attempts":     msg.Attempts + 1,
+		"published_at": &now,
+	}).Error
+}
+
+// outboxBackoff menghitung jeda sebelum percobaan berikutnya: 1s, 2s, 4s, ... hingga max.
+func outboxBackoff(attempts int, max time.Duration) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return delay
+}
@@ -0,0 +1,15 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, outboxBackoff(1, time.Minute))
+	assert.Equal(t, 2*time.Second, outboxBackoff(2, time.Minute))
+	assert.Equal(t, 8*time.Second, outboxBackoff(4, time.Minute))
+	assert.Equal(t, time.Minute, outboxBackoff(10, time.Minute))
+}
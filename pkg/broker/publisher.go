@@ -3,73 +3,253 @@ package broker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+var (
+	// ErrNacked dikembalikan jika broker menolak (nack) pesan yang dipublish.
+	ErrNacked = errors.New("pesan ditolak broker (nack)")
+
+	// ErrUnroutable dikembalikan oleh publish mandatory jika tidak ada queue
+	// yang ter-bind ke routing key sehingga pesan dikembalikan broker.
+	ErrUnroutable = errors.New("pesan tidak bisa dirutekan ke queue manapun")
+
+	ErrPublisherClosed = errors.New("publisher sudah ditutup")
+)
+
+const publishConfirmTimeout = 5 * time.Second
+
+// Publisher mempublish event lewat channel dari Connection. Channel dipakai
+// dalam mode publisher confirm dan setiap publish diserialisasi dengan mutex
+// karena amqp.Channel tidak aman dipakai publish secara bersamaan.
+// Publish baru return nil setelah broker mengirim ack. Saat koneksi putus,
+// Publish menunggu sampai RabbitMQ tersambung kembali lalu mencoba lagi.
 type Publisher struct {
+	conn *Connection
+
+	mu      sync.Mutex
 	channel *amqp.Channel
+	returns chan amqp.Return
+	closed  atomic.Bool
 }
 
-func NewPublisher(channel *amqp.Channel) (*Publisher, error) {
-	err := channel.ExchangeDeclare(
-		ExchangeName,
-		"topic",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+func NewPublisher(conn *Connection) (*Publisher, error) {
+	p := &Publisher{conn: conn}
+
+	p.mu.Lock()
+	_, err := p.getChannel()
+	p.mu.Unlock()
 	if err != nil {
-		return nil, fmt.Errorf("gagal declare exchange '%s': %w", ExchangeName, err)
+		return nil, err
 	}
 
 	log.Printf("✅ Exchange '%s' (topic) berhasil di-declare", ExchangeName)
 
-	return &Publisher{channel: channel}, nil
+	return p, nil
 }
 
 func (p *Publisher) Publish(event *Event) error {
+	return p.publishEvent(event, false)
+}
+
+// PublishMandatory sama seperti Publish tetapi mengembalikan ErrUnroutable
+// jika belum ada queue yang menerima event tersebut.
+func (p *Publisher) PublishMandatory(event *Event) error {
+	return p.publishEvent(event, true)
+}
+
+func (p *Publisher) PublishEvent(eventType EventType, payload interface{}) error {
+	return p.PublishEventWithContext(context.Background(), eventType, payload)
+}
+
+// PublishEventWithContext mempublish event yang membawa correlation ID dari ctx.
+func (p *Publisher) PublishEventWithContext(ctx context.Context, eventType EventType, payload interface{}) error {
+	event, err := NewEventWithContext(ctx, eventType, payload)
+	if err != nil {
+		return fmt.Errorf("gagal membuat event: %w", err)
+	}
+
+	return p.Publish(event)
+}
+
+// Republish mengirim ulang body pesan mentah ke exchange utama dengan routing key asal.
+// Dipublish sebagai mandatory agar pesan tidak hilang jika queue tujuan sudah tidak ada.
+func (p *Publisher) Republish(routingKey, messageID string, body []byte) error {
+	err := p.publish(ExchangeName, routingKey, true, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageID,
+		Body:         body,
+		Timestamp:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("gagal republish pesan '%s' ke '%s': %w", messageID, routingKey, err)
+	}
+
+	log.Printf("🔁 Pesan di-republish: id=%s, routing_key=%s", messageID, routingKey)
+
+	return nil
+}
+
+func (p *Publisher) publishEvent(event *Event, mandatory bool) error {
 	body, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("gagal serialize event: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	err = p.channel.PublishWithContext(
-		ctx,
-		ExchangeName,
-		string(event.Type),
-		false,
-		false,
-		amqp.Publishing{
-			ContentType:  "application/json",
-			DeliveryMode: amqp.Persistent,
-			Body:         body,
-			Timestamp:    event.Timestamp,
-			Type:         string(event.Type),
+	err = p.publish(ExchangeName, string(event.Type), mandatory, amqp.Publishing{
+		Headers: amqp.Table{
+			HeaderCorrelationID: event.CorrelationID,
+			HeaderCausationID:   event.CausationID,
 		},
-	)
+		ContentType:   "application/json",
+		DeliveryMode:  amqp.Persistent,
+		MessageId:     event.ID,
+		CorrelationId: event.CorrelationID,
+		Body:          body,
+		Timestamp:     event.Timestamp,
+		Type:          string(event.Type),
+	})
 	if err != nil {
 		return fmt.Errorf("gagal publish event '%s': %w", event.Type, err)
 	}
 
-	log.Printf("📤 Event published: type=%s, exchange=%s", event.Type, ExchangeName)
+	log.Printf("[cid=%s] 📤 Event published: type=%s, id=%s, exchange=%s", event.CorrelationID, event.Type, event.ID, ExchangeName)
 
 	return nil
 }
 
-func (p *Publisher) PublishEvent(eventType EventType, payload interface{}) error {
-	event, err := NewEvent(eventType, payload)
+// publish mengirim pesan lalu menunggu confirm dari broker. Jika channel
+// tertutup karena koneksi putus, pesan dikirim ulang setelah channel pulih;
+// consumer harus idempotent karena pesan bisa saja sudah diterima broker.
+func (p *Publisher) publish(exchange, routingKey string, mandatory bool, msg amqp.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if p.closed.Load() {
+			return ErrPublisherClosed
+		}
+
+		ch, err := p.getChannel()
+		if err != nil {
+			return err
+		}
+
+		acked, err := p.publishAndConfirm(ch, exchange, routingKey, mandatory, msg)
+		if err != nil {
+			if !ch.IsClosed() {
+				return err
+			}
+
+			log.Printf("⚠️ Channel publisher tertutup, menunggu RabbitMQ pulih untuk publish '%s'...", routingKey)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		// Broker selalu mengirim basic.return sebelum ack untuk pesan yang sama,
+		// dan publish diserialisasi, jadi return yang ada saat ini milik pesan ini.
+		returned := p.drainReturns()
+
+		if !acked {
+			return ErrNacked
+		}
+		if mandatory && returned != nil {
+			return fmt.Errorf("%w: routing_key=%s (%s)", ErrUnroutable, returned.RoutingKey, returned.ReplyText)
+		}
+
+		return nil
+	}
+}
+
+func (p *Publisher) publishAndConfirm(ch *amqp.Channel, exchange, routingKey string, mandatory bool, msg amqp.Publishing) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), publishConfirmTimeout)
+	defer cancel()
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, mandatory, false, msg)
 	if err != nil {
-		return fmt.Errorf("gagal membuat event: %w", err)
+		return false, err
 	}
 
-	return p.Publish(event)
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("tidak menerima confirm dari broker: %w", err)
+	}
+
+	return acked, nil
+}
+
+func (p *Publisher) drainReturns() *amqp.Return {
+	var last *amqp.Return
+	for {
+		select {
+		case ret, ok := <-p.returns:
+			if !ok {
+				return last
+			}
+			last = &ret
+		default:
+			return last
+		}
+	}
+}
+
+// Close menunggu publish yang sedang menunggu confirm selesai, lalu menutup
+// channel. Publish berikutnya mengembalikan ErrPublisherClosed. Jika ctx habis
+// lebih dulu (misal RabbitMQ sedang down), Close return tanpa menunggu.
+func (p *Publisher) Close(ctx context.Context) error {
+	p.closed.Store(true)
+
+	done := make(chan error, 1)
+	go func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if p.channel == nil || p.channel.IsClosed() {
+			done <- nil
+			return
+		}
+		done <- p.channel.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getChannel membuka ulang channel jika tertutup dan mengaktifkan mode confirm.
+// Pemanggil harus memegang p.mu.
+func (p *Publisher) getChannel() (*amqp.Channel, error) {
+	if p.channel != nil && !p.channel.IsClosed() {
+		return p.channel, nil
+	}
+
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuka channel publisher: %w", err)
+	}
+
+	if err := declareExchanges(ch); err != nil {
+		ch.Close()
+		return nil, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("gagal mengaktifkan publisher confirm: %w", err)
+	}
+
+	p.channel = ch
+	p.returns = ch.NotifyReturn(make(chan amqp.Return, 16))
+	return ch, nil
 }
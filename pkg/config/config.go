@@ -5,14 +5,20 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	RabbitMQ RabbitMQConfig
-	Server   ServerConfig
+	Database  DatabaseConfig
+	RabbitMQ  RabbitMQConfig
+	Server    ServerConfig
+	Services  ServicesConfig
+	Payment   PaymentConfig
+	Delivery  DeliveryConfig
+	Blobstore BlobstoreConfig
+	Admin     AdminConfig
 }
 
 type DatabaseConfig struct {
@@ -21,10 +27,68 @@ type DatabaseConfig struct {
 
 type RabbitMQConfig struct {
 	URL string
+	// Workers dan Prefetch opsional (0 = pakai default consumer masing-masing service).
+	Workers  int
+	Prefetch int
+}
+
+// ServicesConfig berisi alamat service lain yang dipanggil secara sinkron.
+type ServicesConfig struct {
+	// InventoryURL dipakai order service untuk mengambil harga katalog (INVENTORY_URL).
+	InventoryURL string
+}
+
+// PaymentConfig mengatur worker kedaluwarsa payment service.
+type PaymentConfig struct {
+	// ExpiryTTL adalah umur maksimal payment PENDING sebelum di-fail otomatis (PAYMENT_TTL, misal "15m").
+	ExpiryTTL time.Duration
+	// ExpiryInterval adalah jeda antar pemeriksaan payment kedaluwarsa (PAYMENT_EXPIRY_INTERVAL).
+	ExpiryInterval time.Duration
+}
+
+// DeliveryConfig mengatur penugasan kurir di delivery service.
+type DeliveryConfig struct {
+	// AssignmentStrategy adalah strategi pemilihan kurir: nearest, least_loaded
+	// atau round_robin (ASSIGNMENT_STRATEGY).
+	AssignmentStrategy string
+	// DepotLat dan DepotLong adalah lokasi penjemputan paket (DEPOT_LAT, DEPOT_LONG).
+	DepotLat  float64
+	DepotLong float64
+	// LocationEventInterval adalah jeda minimum antar event shipment.location_updated
+	// per shipment (LOCATION_EVENT_INTERVAL, misal "10s").
+	LocationEventInterval time.Duration
+	// AverageSpeedKmh adalah kecepatan kurir untuk ETA selama riwayat lokasi
+	// belum cukup (AVERAGE_SPEED_KMH).
+	AverageSpeedKmh float64
+	// ETAChangeThreshold adalah pergeseran ETA minimum untuk mengirim
+	// shipment.eta_changed lagi (ETA_CHANGE_THRESHOLD, misal "5m").
+	ETAChangeThreshold time.Duration
+	// PickupGeofenceRadiusM dan DestinationGeofenceRadiusM adalah radius (meter)
+	// depot dan tujuan untuk perubahan status otomatis
+	// (PICKUP_GEOFENCE_RADIUS_M, DESTINATION_GEOFENCE_RADIUS_M).
+	PickupGeofenceRadiusM      float64
+	DestinationGeofenceRadiusM float64
+}
+
+// BlobstoreConfig mengatur penyimpanan file, misal lampiran bukti pengiriman.
+type BlobstoreConfig struct {
+	// Driver adalah jenis penyimpanan (BLOBSTORE_DRIVER, default "local").
+	Driver string
+	// Dir adalah direktori root untuk driver local (BLOBSTORE_DIR).
+	Dir string
+}
+
+// AdminConfig mengatur akses ke endpoint /admin.
+type AdminConfig struct {
+	// Token wajib dikirim sebagai "Authorization: Bearer <token>" (ADMIN_TOKEN).
+	// Jika kosong, endpoint /admin dinonaktifkan.
+	Token string
 }
 
 type ServerConfig struct {
 	Port string
+	// ShutdownTimeout adalah batas waktu graceful shutdown (SHUTDOWN_TIMEOUT, misal "15s").
+	ShutdownTimeout time.Duration
 }
 
 
@@ -37,6 +101,7 @@ func LoadConfig() *Config {
 
 	// Enable reading from environment variables
 	viper.AutomaticEnv()
+	setDefaults(viper.GetViper())
 
 	// Try to read config file
 	if err := viper.ReadInConfig(); err != nil {
@@ -47,17 +112,7 @@ func LoadConfig() *Config {
 		log.Printf("✅ Config loaded from: %s", viper.ConfigFileUsed())
 	}
 
-	config := &Config{
-		Database: DatabaseConfig{
-			URL: viper.GetString("DB_URL"),
-		},
-		RabbitMQ: RabbitMQConfig{
-			URL: viper.GetString("MQ_URL"),
-		},
-		Server: ServerConfig{
-			Port: viper.GetString("PORT"),
-		},
-	}
+	config := buildConfig(viper.GetViper())
 
 	// Validation
 	if config.Database.URL == "" {
@@ -83,6 +138,7 @@ func LoadConfigFromPath(configPath string) *Config {
 	viper.SetConfigType("env")
 	viper.AddConfigPath(dir)
 	viper.AutomaticEnv()
+	setDefaults(viper.GetViper())
 
 	if err := viper.ReadInConfig(); err != nil {
 		log.Fatalf("Error reading config file from %s: %v", configPath, err)
@@ -90,15 +146,65 @@ func LoadConfigFromPath(configPath string) *Config {
 
 	log.Printf("✅ Config loaded from: %s", configPath)
 
+	return buildConfig(viper.GetViper())
+}
+
+// setDefaults mengisi nilai default yang dipakai semua loader.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("SHUTDOWN_TIMEOUT", "15s")
+	v.SetDefault("INVENTORY_URL", "http://localhost:8081")
+	v.SetDefault("PAYMENT_TTL", "15m")
+	v.SetDefault("PAYMENT_EXPIRY_INTERVAL", "30s")
+	v.SetDefault("ASSIGNMENT_STRATEGY", "nearest")
+	v.SetDefault("DEPOT_LAT", -6.175392)
+	v.SetDefault("DEPOT_LONG", 106.827153)
+	v.SetDefault("LOCATION_EVENT_INTERVAL", "10s")
+	v.SetDefault("AVERAGE_SPEED_KMH", 25)
+	v.SetDefault("ETA_CHANGE_THRESHOLD", "5m")
+	v.SetDefault("PICKUP_GEOFENCE_RADIUS_M", 200)
+	v.SetDefault("DESTINATION_GEOFENCE_RADIUS_M", 300)
+	v.SetDefault("BLOBSTORE_DRIVER", "local")
+	v.SetDefault("BLOBSTORE_DIR", "./data/blobs")
+}
+
+// buildConfig membaca Config dari v setelah default dan file .env dimuat.
+func buildConfig(v *viper.Viper) *Config {
 	return &Config{
 		Database: DatabaseConfig{
-			URL: viper.GetString("DB_URL"),
+			URL: v.GetString("DB_URL"),
 		},
 		RabbitMQ: RabbitMQConfig{
-			URL: viper.GetString("MQ_URL"),
+			URL:      v.GetString("MQ_URL"),
+			Workers:  v.GetInt("MQ_WORKERS"),
+			Prefetch: v.GetInt("MQ_PREFETCH"),
 		},
 		Server: ServerConfig{
-			Port: viper.GetString("PORT"),
+			Port:            v.GetString("PORT"),
+			ShutdownTimeout: v.GetDuration("SHUTDOWN_TIMEOUT"),
+		},
+		Services: ServicesConfig{
+			InventoryURL: v.GetString("INVENTORY_URL"),
+		},
+		Payment: PaymentConfig{
+			ExpiryTTL:      v.GetDuration("PAYMENT_TTL"),
+			ExpiryInterval: v.GetDuration("PAYMENT_EXPIRY_INTERVAL"),
+		},
+		Delivery: DeliveryConfig{
+			AssignmentStrategy:         v.GetString("ASSIGNMENT_STRATEGY"),
+			DepotLat:                   v.GetFloat64("DEPOT_LAT"),
+			DepotLong:                  v.GetFloat64("DEPOT_LONG"),
+			LocationEventInterval:      v.GetDuration("LOCATION_EVENT_INTERVAL"),
+			AverageSpeedKmh:            v.GetFloat64("AVERAGE_SPEED_KMH"),
+			ETAChangeThreshold:         v.GetDuration("ETA_CHANGE_THRESHOLD"),
+			PickupGeofenceRadiusM:      v.GetFloat64("PICKUP_GEOFENCE_RADIUS_M"),
+			DestinationGeofenceRadiusM: v.GetFloat64("DESTINATION_GEOFENCE_RADIUS_M"),
+		},
+		Blobstore: BlobstoreConfig{
+			Driver: v.GetString("BLOBSTORE_DRIVER"),
+			Dir:    v.GetString("BLOBSTORE_DIR"),
+		},
+		Admin: AdminConfig{
+			Token: v.GetString("ADMIN_TOKEN"),
 		},
 	}
 }
@@ -117,6 +223,23 @@ func (c *Config) PrintConfig() {
 	fmt.Printf("   Database: %s\n", maskURL(c.Database.URL))
 	fmt.Printf("   RabbitMQ: %s\n", maskURL(c.RabbitMQ.URL))
 	fmt.Printf("   Server Port: %s\n", c.Server.Port)
+	fmt.Printf("   Shutdown Timeout: %s\n", c.Server.ShutdownTimeout)
+	fmt.Printf("   Inventory URL: %s\n", c.Services.InventoryURL)
+	fmt.Printf("   Payment TTL: %s (cek setiap %s)\n", c.Payment.ExpiryTTL, c.Payment.ExpiryInterval)
+	fmt.Printf("   Assignment: %s (depot %f, %f)\n", c.Delivery.AssignmentStrategy, c.Delivery.DepotLat, c.Delivery.DepotLong)
+	fmt.Printf("   Location Event Interval: %s\n", c.Delivery.LocationEventInterval)
+	fmt.Printf("   ETA: %.1f km/jam default, event jika bergeser >= %s\n", c.Delivery.AverageSpeedKmh, c.Delivery.ETAChangeThreshold)
+	fmt.Printf("   Geofence: depot %.0f m, tujuan %.0f m\n", c.Delivery.PickupGeofenceRadiusM, c.Delivery.DestinationGeofenceRadiusM)
+	fmt.Printf("   Blobstore: %s (%s)\n", c.Blobstore.Driver, c.Blobstore.Dir)
+	fmt.Printf("   Admin API: %s\n", adminStatus(c.Admin.Token))
+}
+
+// adminStatus menampilkan apakah endpoint /admin aktif tanpa mencetak token.
+func adminStatus(token string) string {
+	if token == "" {
+		return "nonaktif (ADMIN_TOKEN kosong)"
+	}
+	return "aktif"
 }
 
 // maskURL menyembunyikan password dalam URL untuk logging
@@ -0,0 +1,59 @@
+// Package correlation membawa correlation ID dan causation ID lewat
+// context.Context sehingga satu alur (HTTP request → event → event
+// berikutnya) bisa dilacak di log semua service.
+package correlation
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// Header HTTP yang dibaca dan dikembalikan oleh middleware Gin.
+const Header = "X-Correlation-ID"
+
+type contextKey int
+
+const (
+	correlationIDKey contextKey = iota
+	causationIDKey
+)
+
+func NewID() string {
+	return uuid.NewString()
+}
+
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// CorrelationID mengembalikan correlation ID di ctx, atau "" jika tidak ada.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
+
+// WithCausationID menandai ID event yang menyebabkan event berikutnya dipublish.
+func WithCausationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, causationIDKey, id)
+}
+
+func CausationID(ctx context.Context) string {
+	id, _ := ctx.Value(causationIDKey).(string)
+	return id
+}
+
+// Logf sama seperti log.Printf tetapi diawali correlation ID dari ctx.
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	log.Print(Prefix(ctx) + fmt.Sprintf(format, args...))
+}
+
+// Prefix mengembalikan "[cid=...] " atau "" jika ctx tidak punya correlation ID.
+func Prefix(ctx context.Context) string {
+	if id := CorrelationID(ctx); id != "" {
+		return "[cid=" + id + "] "
+	}
+	return ""
+}
@@ -0,0 +1,22 @@
+package correlation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextIDs(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, CorrelationID(ctx))
+	assert.Empty(t, CausationID(ctx))
+	assert.Empty(t, Prefix(ctx))
+
+	ctx = WithCorrelationID(ctx, "req-1")
+	ctx = WithCausationID(ctx, "evt-1")
+
+	assert.Equal(t, "req-1", CorrelationID(ctx))
+	assert.Equal(t, "evt-1", CausationID(ctx))
+	assert.Equal(t, "[cid=req-1] ", Prefix(ctx))
+}
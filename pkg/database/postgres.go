@@ -30,3 +30,12 @@ func InitPostgres(dsn string) *gorm.DB {
 
 	return db
 }
+
+// Close menutup connection pool milik db.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,43 @@
+// Package geo berisi perhitungan koordinat sederhana untuk tracking kurir.
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// EarthRadiusKm adalah radius rata-rata bumi yang dipakai rumus haversine.
+const EarthRadiusKm = 6371.0
+
+// Point adalah satu koordinat lintang/bujur dalam derajat.
+type Point struct {
+	Lat  float64 `json:"lat"`
+	Long float64 `json:"long"`
+}
+
+// Validate memastikan koordinat berada dalam rentang lintang/bujur yang sah.
+func (p Point) Validate() error {
+	if p.Lat < -90 || p.Lat > 90 {
+		return fmt.Errorf("latitude tidak valid: %f (harus antara -90 dan 90)", p.Lat)
+	}
+	if p.Long < -180 || p.Long > 180 {
+		return fmt.Errorf("longitude tidak valid: %f (harus antara -180 dan 180)", p.Long)
+	}
+	return nil
+}
+
+// DistanceKm menghitung jarak great-circle antara a dan b dalam kilometer
+// dengan rumus haversine.
+func DistanceKm(a, b Point) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat := lat2 - lat1
+	dLong := radians(b.Long - a.Long)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,26 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistanceKm(t *testing.T) {
+	monas := Point{Lat: -6.175392, Long: 106.827153}
+	bandung := Point{Lat: -6.914744, Long: 107.609810}
+
+	assert.InDelta(t, 0, DistanceKm(monas, monas), 1e-9)
+	// Jarak garis lurus Jakarta–Bandung sekitar 119 km
+	assert.InDelta(t, 119.3, DistanceKm(monas, bandung), 1)
+	assert.InDelta(t, DistanceKm(monas, bandung), DistanceKm(bandung, monas), 1e-9)
+
+	// Setengah keliling bumi
+	assert.InDelta(t, 20015.1, DistanceKm(Point{0, 0}, Point{0, 180}), 0.5)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Point{Lat: -6.2, Long: 106.8}.Validate())
+	assert.Error(t, Point{Lat: 91, Long: 0}.Validate())
+	assert.Error(t, Point{Lat: 0, Long: -181}.Validate())
+}
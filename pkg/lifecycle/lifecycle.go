@@ -0,0 +1,154 @@
+// Package lifecycle menjalankan HTTP server dan goroutine background sebuah
+// service, lalu mematikannya secara berurutan saat menerima SIGINT/SIGTERM.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const DefaultShutdownTimeout = 15 * time.Second
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Lifecycle mengatur urutan shutdown:
+//  1. HTTP server berhenti menerima koneksi baru dan menunggu request berjalan,
+//  2. Context dibatalkan dan goroutine dari Go ditunggu selesai,
+//  3. hook OnShutdown dijalankan sesuai urutan pendaftaran.
+//
+// Semua langkah berbagi satu deadline (timeout).
+type Lifecycle struct {
+	timeout time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	hooks []hook
+}
+
+func New(timeout time.Duration) *Lifecycle {
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Context dibatalkan saat shutdown dimulai.
+func (l *Lifecycle) Context() context.Context {
+	return l.ctx
+}
+
+// Go menjalankan fn di goroutine dengan Context; shutdown menunggu fn return.
+func (l *Lifecycle) Go(name string, fn func(ctx context.Context)) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn(l.ctx)
+		log.Printf("⚠️ %s berhenti", name)
+	}()
+}
+
+// OnShutdown mendaftarkan langkah shutdown, misalnya menutup consumer atau DB.
+func (l *Lifecycle) OnShutdown(name string, fn func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook{name: name, fn: fn})
+}
+
+// Run menjalankan server sampai menerima SIGINT/SIGTERM atau server gagal,
+// lalu menjalankan Shutdown.
+func (l *Lifecycle) Run(server *http.Server) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var runErr error
+	select {
+	case sig := <-signals:
+		log.Printf("🛑 Sinyal %s diterima, memulai graceful shutdown (batas %s)...", sig, l.timeout)
+	case runErr = <-serverErr:
+		log.Printf("❌ HTTP server berhenti: %v", runErr)
+	}
+
+	return errors.Join(runErr, l.Shutdown(server))
+}
+
+// Shutdown menghentikan server (boleh nil), goroutine background, lalu hook.
+// Hook tetap dijalankan walaupun langkah sebelumnya gagal atau deadline habis,
+// agar koneksi tetap ditutup.
+func (l *Lifecycle) Shutdown(server *http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	var errs []error
+
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("⚠️ HTTP server tidak berhenti dengan bersih: %v", err)
+			errs = append(errs, err)
+		} else {
+			log.Println("✅ HTTP server berhenti")
+		}
+	}
+
+	l.cancel()
+	if err := wait(ctx, &l.wg); err != nil {
+		log.Printf("⚠️ Goroutine background belum selesai: %v", err)
+		errs = append(errs, err)
+	}
+
+	l.mu.Lock()
+	hooks := append([]hook(nil), l.hooks...)
+	l.mu.Unlock()
+
+	for _, h := range hooks {
+		if err := h.fn(ctx); err != nil {
+			log.Printf("⚠️ Shutdown '%s' gagal: %v", h.name, err)
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("✅ Shutdown '%s' selesai", h.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
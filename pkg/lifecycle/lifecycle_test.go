@@ -0,0 +1,66 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	l := New(time.Second)
+
+	var steps []string
+	stopped := make(chan struct{})
+	l.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		steps = append(steps, "worker")
+		close(stopped)
+	})
+	l.OnShutdown("consumer", func(ctx context.Context) error {
+		<-stopped
+		steps = append(steps, "consumer")
+		return nil
+	})
+	l.OnShutdown("database", func(ctx context.Context) error {
+		steps = append(steps, "database")
+		return nil
+	})
+
+	server := &http.Server{Addr: "127.0.0.1:0"}
+	assert.NoError(t, l.Shutdown(server))
+	assert.Equal(t, []string{"worker", "consumer", "database"}, steps)
+	assert.Error(t, l.Context().Err())
+}
+
+func TestShutdownRunsAllHooksAndJoinsErrors(t *testing.T) {
+	l := New(time.Second)
+
+	errConsumer := errors.New("consumer gagal")
+	var dbClosed bool
+	l.OnShutdown("consumer", func(ctx context.Context) error { return errConsumer })
+	l.OnShutdown("database", func(ctx context.Context) error {
+		dbClosed = true
+		return nil
+	})
+
+	err := l.Shutdown(nil)
+	assert.ErrorIs(t, err, errConsumer)
+	assert.True(t, dbClosed)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	l := New(20 * time.Millisecond)
+
+	l.Go("stuck", func(ctx context.Context) {
+		time.Sleep(time.Second)
+	})
+
+	start := time.Now()
+	err := l.Shutdown(nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+)
+
+// validCorrelationID membatasi ID dari client ke karakter aman untuk log, JSON,
+// dan header (termasuk UUID), maksimal 64 karakter agar muat di kolom varchar(64).
+var validCorrelationID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// CorrelationID menerima X-Correlation-ID yang valid dari client atau membuat yang baru,
+// menyimpannya di context request, dan mengembalikannya di response header.
+func CorrelationID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(correlation.Header)
+		if !validCorrelationID.MatchString(id) {
+			id = correlation.NewID()
+		}
+
+		c.Request = c.Request.WithContext(correlation.WithCorrelationID(c.Request.Context(), id))
+		c.Header(correlation.Header, id)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/purnama/Event-Driven-Logistic/pkg/correlation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrelationID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(CorrelationID())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, correlation.CorrelationID(c.Request.Context()))
+	})
+
+	t.Run("accepts client ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(correlation.Header, "abc-123")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "abc-123", w.Body.String())
+		assert.Equal(t, "abc-123", w.Header().Get(correlation.Header))
+	})
+
+	t.Run("mints ID when missing", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.NotEmpty(t, w.Body.String())
+		assert.Equal(t, w.Body.String(), w.Header().Get(correlation.Header))
+	})
+
+	t.Run("replaces invalid ID", func(t *testing.T) {
+		for _, id := range []string{`abc"}`, "abc\ndef", strings.Repeat("a", 65)} {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(correlation.Header, id)
+			router.ServeHTTP(w, req)
+
+			assert.NotEqual(t, id, w.Body.String())
+			assert.Equal(t, w.Body.String(), w.Header().Get(correlation.Header))
+		}
+	})
+}
@@ -0,0 +1,252 @@
+// Package money menyimpan nominal uang sebagai bilangan bulat dalam satuan
+// terkecil (sen) beserta kode currency, sehingga tidak ada pembulatan float.
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale adalah jumlah satuan terkecil per satu unit currency (2 desimal),
+// sama dengan kolom DECIMAL(12,2) di database.
+const Scale = 100
+
+// DefaultCurrency dipakai jika nominal tidak membawa currency, misalnya
+// payload lama yang masih berupa angka float.
+const DefaultCurrency = "IDR"
+
+// ErrCurrencyMismatch dikembalikan saat menjumlahkan dua currency berbeda.
+var ErrCurrencyMismatch = errors.New("currency berbeda")
+
+// ErrInvalidAmount dikembalikan jika nominal tidak dapat di-parse.
+var ErrInvalidAmount = errors.New("nominal uang tidak valid")
+
+// Money adalah nominal uang. Amount dalam satuan terkecil (1/Scale).
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// New membuat Money dari nominal satuan terkecil.
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: strings.ToUpper(currency)}
+}
+
+// FromFloat mengonversi nominal float (format lama) ke Money, dibulatkan ke sen terdekat.
+func FromFloat(value float64, currency string) Money {
+	return New(int64(math.Round(value*Scale)), currency)
+}
+
+// Parse membaca nominal desimal seperti "15000", "15000.5" atau "IDR 15000.50".
+// currency dipakai jika string tidak membawa kode currency.
+func Parse(s string, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if code, rest, ok := strings.Cut(s, " "); ok {
+		currency, s = code, strings.TrimSpace(rest)
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" || len(frac) > 2 {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || cents < 0 {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	minor := units*Scale + cents
+	if negative {
+		minor = -minor
+	}
+	return New(minor, currency), nil
+}
+
+// Decimal mengembalikan nominal dalam format desimal tanpa currency, misal "15000.00".
+func (m Money) Decimal() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/Scale, amount%Scale)
+}
+
+// String mengembalikan nominal beserta currency, misal "IDR 15000.00".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Currency + " " + m.Decimal()
+}
+
+// IsPositive bernilai true jika nominal lebih dari 0.
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative bernilai true jika nominal kurang dari 0.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Mul mengalikan nominal dengan bilangan bulat, misalnya harga satuan × quantity.
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Add menjumlahkan dua nominal. Nominal tanpa currency mengikuti currency lawannya.
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.sameCurrency(other)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: currency}, nil
+}
+
+// Sub mengurangi nominal dengan other.
+func (m Money) Sub(other Money) (Money, error) {
+	currency, err := m.sameCurrency(other)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: currency}, nil
+}
+
+func (m Money) sameCurrency(other Money) (string, error) {
+	switch {
+	case m.Currency == "":
+		return other.Currency, nil
+	case other.Currency == "" || other.Currency == m.Currency:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s vs %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+// WithDefaultCurrency mengisi currency dengan DefaultCurrency jika kosong.
+func (m Money) WithDefaultCurrency() Money {
+	if m.Currency == "" {
+		m.Currency = DefaultCurrency
+	}
+	return m
+}
+
+type jsonMoney struct {
+	Value    json.RawMessage `json:"value"`
+	Currency string          `json:"currency,omitempty"`
+}
+
+// MarshalJSON menulis {"value":"15000.00","currency":"IDR"}; value berupa
+// string agar client tidak kehilangan presisi.
+func (m Money) MarshalJSON() ([]byte, error) {
+	value, _ := json.Marshal(m.Decimal())
+	return json.Marshal(jsonMoney{Value: value, Currency: m.Currency})
+}
+
+// UnmarshalJSON menerima format objek dari MarshalJSON, string desimal,
+// dan (mode kompatibilitas) angka float dari payload lama.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var obj jsonMoney
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		parsed, err := parseJSONValue(obj.Value, obj.Currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	parsed, err := parseJSONValue(data, "")
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func parseJSONValue(data json.RawMessage, currency string) (Money, error) {
+	if len(data) == 0 {
+		return Money{}, fmt.Errorf("%w: value kosong", ErrInvalidAmount)
+	}
+
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return Money{}, err
+		}
+		return Parse(s, currency)
+	}
+
+	// Mode kompatibilitas: payload lama menulis nominal sebagai angka float
+	// JSON, misal 15000.5; dibulatkan ke sen terdekat.
+	value, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+	}
+	return FromFloat(value, currency), nil
+}
+
+// Value menyimpan nominal sebagai desimal (tanpa currency) agar cocok dengan
+// kolom DECIMAL(12,2); currency disimpan di kolom terpisah oleh model.
+func (m Money) Value() (driver.Value, error) {
+	return m.Decimal(), nil
+}
+
+// Scan membaca kolom DECIMAL/NUMERIC. Currency tidak ikut dibaca dan
+// harus diisi model dari kolom currency-nya.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = Money{Amount: v * Scale, Currency: m.Currency}
+		return nil
+	case float64:
+		*m = FromFloat(v, m.Currency)
+		return nil
+	}
+	return fmt.Errorf("%w: tipe %T tidak didukung", ErrInvalidAmount, src)
+}
+
+func (m *Money) scanString(s string) error {
+	// Postgres bisa mengembalikan NUMERIC dengan lebih dari 2 desimal
+	if whole, frac, ok := strings.Cut(s, "."); ok && len(frac) > 2 {
+		s = whole + "." + strings.TrimRight(frac, "0")
+		if strings.HasSuffix(s, ".") {
+			s = whole
+		}
+	}
+	parsed, err := Parse(s, m.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
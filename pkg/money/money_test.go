@@ -0,0 +1,97 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"15000", New(1500000, "IDR")},
+		{"15000.5", New(1500050, "IDR")},
+		{"15000.05", New(1500005, "IDR")},
+		{"USD 12.34", New(1234, "USD")},
+		{"-0.50", New(-50, "IDR")},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in, "IDR")
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, in := range []string{"", "abc", "1.234", "1.-5"} {
+		_, err := Parse(in, "IDR")
+		assert.True(t, errors.Is(err, ErrInvalidAmount), in)
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "IDR 15000.00", New(1500000, "idr").String())
+	assert.Equal(t, "-0.05", New(-5, "").String())
+}
+
+func TestArithmetic(t *testing.T) {
+	price := New(2500050, "IDR")
+	total, err := price.Mul(3).Add(New(1, "IDR"))
+	require.NoError(t, err)
+	assert.Equal(t, New(7500151, "IDR"), total)
+
+	_, err = price.Add(New(100, "USD"))
+	assert.True(t, errors.Is(err, ErrCurrencyMismatch))
+
+	sum, err := Money{}.Add(price)
+	require.NoError(t, err)
+	assert.Equal(t, price, sum)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	data, err := json.Marshal(New(1500050, "IDR"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"value":"15000.50","currency":"IDR"}`, string(data))
+
+	var got Money
+	require.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, New(1500050, "IDR"), got)
+}
+
+func TestUnmarshalLegacyFloat(t *testing.T) {
+	var payload struct {
+		TotalPrice Money `json:"total_price"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"total_price": 0.30000000000000004}`), &payload))
+	assert.Equal(t, New(30, ""), payload.TotalPrice)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"total_price": 15000000}`), &payload))
+	assert.Equal(t, New(1500000000, ""), payload.TotalPrice)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"total_price": "IDR 10.10"}`), &payload))
+	assert.Equal(t, New(1010, "IDR"), payload.TotalPrice)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"total_price": {"value": 99.99, "currency": "usd"}}`), &payload))
+	assert.Equal(t, New(9999, "USD"), payload.TotalPrice)
+}
+
+func TestValueScan(t *testing.T) {
+	value, err := New(1500050, "IDR").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "15000.50", value)
+
+	for _, src := range []interface{}{[]byte("15000.50"), "15000.5000", float64(15000.5)} {
+		got := Money{Currency: "IDR"}
+		require.NoError(t, got.Scan(src))
+		assert.Equal(t, New(1500050, "IDR"), got, "%v", src)
+	}
+
+	var got Money
+	require.NoError(t, got.Scan(int64(7)))
+	assert.Equal(t, New(700, ""), got)
+	require.NoError(t, got.Scan(nil))
+	assert.Equal(t, Money{}, got)
+}